@@ -32,23 +32,41 @@ data "aws_iam_policy_document" "allow_lambda_access_policy_doc" {
     sid    = "1"
     effect = "Allow"
 
-    actions   = ["dynamodb:Scan", "dynamodb:Query", "dynamodb:UpdateItem", "dynamodb:GetItem", "dynamodb:DeleteItem", "dynamodb:PutItem"]
-    resources = [aws_dynamodb_table.movies_db.arn]
+    actions   = ["dynamodb:Scan", "dynamodb:Query", "dynamodb:UpdateItem", "dynamodb:GetItem", "dynamodb:DeleteItem", "dynamodb:PutItem", "dynamodb:ConditionCheckItem", "dynamodb:BatchWriteItem", "dynamodb:BatchGetItem"]
+    resources = [aws_dynamodb_table.movies_db.arn, "${aws_dynamodb_table.movies_db.arn}/index/*", aws_dynamodb_table.movie_titles_db.arn, aws_dynamodb_table.movie_search_db.arn]
   }
   statement {
     sid    = "2"
     effect = "Allow"
 
     actions   = ["bedrock:InvokeModel"]
-    resources = ["arn:aws:bedrock:ap-south-1::foundation-model/anthropic.claude-3-sonnet-20240229-v1:0"]
+    resources = ["arn:aws:bedrock:${var.aws_region}::foundation-model/${var.bedrock_model_id}"]
   }
   statement {
     sid    = "3"
     effect = "Allow"
 
-    actions   = ["s3:PutObject", "s3:DeleteObject"]
+    # GetObject backs the presigned cover URLs.
+    actions   = ["s3:PutObject", "s3:GetObject", "s3:DeleteObject"]
     resources = ["${aws_s3_bucket.movies_rest_api_bucket.arn}/${var.s3_images_prefix}/*"]
   }
+  statement {
+    sid    = "4"
+    effect = "Allow"
+
+    # Presigned upload URLs are signed with the Lambda role, so it needs
+    # PutObject on the uploads it confirms.
+    actions   = ["s3:PutObject", "s3:GetObject", "s3:DeleteObject"]
+    resources = ["${aws_s3_bucket.movies_rest_api_bucket.arn}/${var.s3_uploads_prefix}/*"]
+  }
+  statement {
+    sid    = "5"
+    effect = "Allow"
+
+    # Read by the search indexer through its event source mapping.
+    actions   = ["dynamodb:DescribeStream", "dynamodb:GetRecords", "dynamodb:GetShardIterator", "dynamodb:ListStreams"]
+    resources = ["${aws_dynamodb_table.movies_db.arn}/stream/*"]
+  }
 }
 
 data "archive_file" "lambda" {
@@ -56,3 +74,9 @@ data "archive_file" "lambda" {
   source_file = "${path.module}/../lambda-code/bootstrap"
   output_path = "lambda_function_payload.zip"
 }
+
+data "archive_file" "indexer" {
+  type        = "zip"
+  source_file = "${path.module}/../lambda-code/cmd/indexer/bootstrap"
+  output_path = "indexer_function_payload.zip"
+}
@@ -26,36 +26,88 @@ resource "aws_s3_object" "movies_rest_api_images" {
   content_type = "application/octet-stream"
 }
 
+# With private_bucket the images are not public and the Lambda returns
+# presigned URLs for them instead.
 resource "aws_s3_bucket_public_access_block" "movies_rest_api_bucket_public_access" {
   bucket = aws_s3_bucket.movies_rest_api_bucket.id
 
-  block_public_acls       = false
-  block_public_policy     = false
-  ignore_public_acls      = false
-  restrict_public_buckets = false
+  block_public_acls       = var.private_bucket
+  block_public_policy     = var.private_bucket
+  ignore_public_acls      = var.private_bucket
+  restrict_public_buckets = var.private_bucket
 
 }
 
 resource "aws_s3_bucket_policy" "allow_get_images_policy" {
+  count  = var.private_bucket ? 0 : 1
   bucket = aws_s3_bucket.movies_rest_api_bucket.id
   policy = data.aws_iam_policy_document.allow_get_s3_images_policy.json
 }
 
+# Browsers PUT covers straight to the bucket with presigned URLs.
+resource "aws_s3_bucket_cors_configuration" "movies_rest_api_bucket_cors" {
+  bucket = aws_s3_bucket.movies_rest_api_bucket.id
+
+  cors_rule {
+    allowed_methods = ["PUT"]
+    allowed_origins = var.cover_upload_origins
+    allowed_headers = ["Content-Type"]
+    max_age_seconds = 3000
+  }
+}
+
+# Uploads that are never confirmed are removed after a day.
+resource "aws_s3_bucket_lifecycle_configuration" "movies_rest_api_bucket_lifecycle" {
+  bucket = aws_s3_bucket.movies_rest_api_bucket.id
+
+  rule {
+    id     = "expire-cover-uploads"
+    status = "Enabled"
+
+    filter {
+      prefix = "${var.s3_uploads_prefix}/"
+    }
+
+    expiration {
+      days = 1
+    }
+  }
+}
+
 # DynamoDB
 resource "aws_dynamodb_table" "movies_db" {
-  name         = "Movies"
+  name         = var.table_name
   billing_mode = "PAY_PER_REQUEST"
   hash_key     = "movieId"
   # range_key    = "releaseYear"
 
+  # The indexer Lambda reads the changes from the stream to update the search
+  # index, comparing each movie before and after.
+  stream_enabled   = true
+  stream_view_type = "NEW_AND_OLD_IMAGES"
+
   attribute {
     name = "movieId"
     type = "S"
   }
-  # attribute {
-  #   name = "releaseYear"
-  #   type = "N"
-  # }
+  attribute {
+    name = "entityType"
+    type = "S"
+  }
+  attribute {
+    name = "releaseYear"
+    type = "N"
+  }
+
+  # Every movie item has entityType = "movie", so this index keeps the whole
+  # catalogue in one partition sorted by releaseYear. Year and year range
+  # lookups become a single Query instead of a full table Scan.
+  global_secondary_index {
+    name            = "releaseYear-index"
+    hash_key        = "entityType"
+    range_key       = "releaseYear"
+    projection_type = "ALL"
+  }
 
   tags = {
     "Name"        = "Movies REST API"
@@ -73,15 +125,74 @@ resource "aws_dynamodb_table_item" "movie_item" {
   item = <<ITEM
   {
   "movieId": {"S": "${local.movie_data[count.index].movieId}"},
+  "entityType": {"S": "movie"},
   "title": {"S": "${local.movie_data[count.index].title}"},
   "releaseYear": {"N": "${local.movie_data[count.index].releaseYear}"},
-  "genre": {"S": "${local.movie_data[count.index].genre}"},
-  "coverUrl": {"S": "${local.movie_data[count.index].coverUrl}"},
-  "generatedSummary": {"S": ""}
+  "genre": {"SS": ${jsonencode(local.movie_data[count.index].genre)}},
+  "coverKey": {"S": "${local.movie_data[count.index].coverKey}"},
+  "generatedSummary": {"S": ""},
+  "version": {"N": "1"}
+  }
+  ITEM
+}
+
+# One item per normalized (lower-cased, whitespace collapsed) title. The Lambda
+# writes it in the same transaction as the movie to keep titles unique.
+resource "aws_dynamodb_table" "movie_titles_db" {
+  name         = var.titles_table_name
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "normalizedTitle"
+
+  attribute {
+    name = "normalizedTitle"
+    type = "S"
+  }
+
+  tags = {
+    "Name"        = "Movies REST API"
+    "Environment" = "Dev"
+  }
+}
+
+resource "aws_dynamodb_table_item" "movie_title_item" {
+  table_name = aws_dynamodb_table.movie_titles_db.name
+  hash_key   = aws_dynamodb_table.movie_titles_db.hash_key
+
+  count = length(local.movie_data)
+
+  item = <<ITEM
+  {
+  "normalizedTitle": {"S": "${lower(join(" ", compact(split(" ", trimspace(local.movie_data[count.index].title)))))}"},
+  "movieId": {"S": "${local.movie_data[count.index].movieId}"}
   }
   ITEM
 }
 
+# Inverted index for GET /api/movies/search, one item per token and movie.
+# The indexer Lambda keeps it up to date from the movies table stream; run
+# lambda-code/cmd/reindex once after creating it to index the seeded movies.
+resource "aws_dynamodb_table" "movie_search_db" {
+  name         = var.search_table_name
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "token"
+  range_key    = "movieId"
+
+  attribute {
+    name = "token"
+    type = "S"
+  }
+
+  attribute {
+    name = "movieId"
+    type = "S"
+  }
+
+  tags = {
+    "Name"        = "Movies REST API"
+    "Environment" = "Dev"
+  }
+}
+
 # Lambda
 resource "aws_lambda_function" "movies_api_lambda" {
   function_name = "movies_api_lambda"
@@ -95,7 +206,21 @@ resource "aws_lambda_function" "movies_api_lambda" {
   source_code_hash = data.archive_file.lambda.output_base64sha256
   environment {
     variables = {
-      REGION = var.aws_region
+      REGION              = var.aws_region
+      TABLE_NAME          = aws_dynamodb_table.movies_db.name
+      TITLES_TABLE_NAME   = aws_dynamodb_table.movie_titles_db.name
+      SEARCH_TABLE_NAME   = aws_dynamodb_table.movie_search_db.name
+      BUCKET_NAME         = aws_s3_bucket.movies_rest_api_bucket.id
+      IMAGE_PREFIX        = var.s3_images_prefix
+      UPLOAD_PREFIX       = var.s3_uploads_prefix
+      COVER_URL_MODE      = var.private_bucket ? "presigned" : var.cover_url_mode
+      COVER_BASE_URL      = var.cover_base_url
+      COVER_URL_TTL       = var.cover_url_ttl
+      MODEL_ID            = var.bedrock_model_id
+      MAX_UPLOAD_BYTES    = var.max_upload_bytes
+      MAX_COVER_BYTES     = var.max_cover_bytes
+      MAX_COVER_DIMENSION = var.max_cover_dimension
+      LOG_LEVEL           = var.log_level
     }
   }
 
@@ -105,6 +230,44 @@ resource "aws_lambda_function" "movies_api_lambda" {
   }
 }
 
+# Search indexer
+resource "aws_lambda_function" "movies_search_indexer" {
+  function_name = "movies_search_indexer"
+  role          = aws_iam_role.lambda_execution_role.arn
+  runtime       = "provided.al2023"
+  handler       = "main"
+  filename      = "${path.module}/indexer_function_payload.zip"
+
+  timeout = 60
+
+  source_code_hash = data.archive_file.indexer.output_base64sha256
+  environment {
+    variables = {
+      REGION            = var.aws_region
+      TABLE_NAME        = aws_dynamodb_table.movies_db.name
+      SEARCH_TABLE_NAME = aws_dynamodb_table.movie_search_db.name
+      LOG_LEVEL         = var.log_level
+    }
+  }
+
+  tags = {
+    Name        = "Movies REST API"
+    Environment = "Dev"
+  }
+}
+
+# Records are retried from the first one the indexer reports as failed. A
+# record still failing after the retries is dropped and left to
+# lambda-code/cmd/reindex.
+resource "aws_lambda_event_source_mapping" "movies_search_indexer_stream" {
+  event_source_arn        = aws_dynamodb_table.movies_db.stream_arn
+  function_name           = aws_lambda_function.movies_search_indexer.arn
+  starting_position       = "LATEST"
+  batch_size              = 100
+  maximum_retry_attempts  = 10
+  function_response_types = ["ReportBatchItemFailures"]
+}
+
 resource "aws_iam_role" "lambda_execution_role" {
   name               = "lambda_execution_role"
   assume_role_policy = data.aws_iam_policy_document.lambda_execution_policy.json
@@ -16,8 +16,92 @@ variable "s3_images_prefix" {
   default     = "images"
 }
 
+variable "s3_uploads_prefix" {
+  description = "AWS s3 folder direct cover uploads are staged in, kept private"
+  type        = string
+  default     = "uploads"
+}
+
+variable "private_bucket" {
+  description = "Keep the bucket private and serve covers through presigned URLs, overriding cover_url_mode"
+  type        = bool
+  default     = false
+}
+
+variable "cover_url_mode" {
+  description = "How cover URLs are rendered: s3, cdn or presigned"
+  type        = string
+  default     = "s3"
+}
+
+variable "cover_url_ttl" {
+  description = "How long presigned cover URLs are valid, e.g. 15m"
+  type        = string
+  default     = "1h"
+}
+
+variable "cover_base_url" {
+  description = "Base URL of the bucket when cover_url_mode is cdn, e.g. a CloudFront domain"
+  type        = string
+  default     = ""
+}
+
+variable "cover_upload_origins" {
+  description = "Origins allowed to PUT covers to presigned upload URLs"
+  type        = list(string)
+  default     = ["*"]
+}
+
 variable "local_images_folder" {
   description = "Local folder name of the images"
   type        = string
   default     = "images"
 }
+
+variable "table_name" {
+  description = "DynamoDB table holding the movies"
+  type        = string
+  default     = "Movies"
+}
+
+variable "titles_table_name" {
+  description = "DynamoDB table enforcing unique movie titles"
+  type        = string
+  default     = "MovieTitles"
+}
+
+variable "search_table_name" {
+  description = "DynamoDB table holding the movie search index"
+  type        = string
+  default     = "MovieSearchIndex"
+}
+
+variable "bedrock_model_id" {
+  description = "Bedrock foundation model used to generate movie summaries"
+  type        = string
+  default     = "anthropic.claude-3-sonnet-20240229-v1:0"
+}
+
+variable "max_upload_bytes" {
+  description = "Largest multipart request body the Lambda accepts, in bytes"
+  type        = number
+  default     = 10485760
+}
+
+variable "max_cover_bytes" {
+  description = "Largest cover image the Lambda accepts, in bytes"
+  type        = number
+  default     = 5242880
+}
+
+variable "max_cover_dimension" {
+  description = "Largest width and height of a cover image, in pixels"
+  type        = number
+  default     = 4096
+}
+
+variable "log_level" {
+  description = "Lambda log level: debug, info, warn or error"
+  type        = string
+  default     = "info"
+}
@@ -0,0 +1,29 @@
+package api
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// LoadAWSConfig loads the SDK configuration shared by the DynamoDB, S3 and
+// Bedrock clients. It is loaded once per cold start; per service endpoint
+// overrides are applied when each client is created.
+func LoadAWSConfig(ctx context.Context, appConfig Config) (aws.Config, error) {
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(appConfig.Region))
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("unable to load AWS SDK config: %w", err)
+	}
+	return cfg, nil
+}
+
+// baseEndpoint returns the BaseEndpoint client option for an endpoint
+// override, or nil when the service's default endpoint should be used.
+func baseEndpoint(endpoint string) *string {
+	if endpoint == "" {
+		return nil
+	}
+	return aws.String(endpoint)
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// Summarizer generates a short summary for a movie. Model failures are
+// returned as an UpstreamError.
+type Summarizer interface {
+	GenerateMovieSummary(ctx context.Context, movie Movie) (string, error)
+}
+
+// BedrockSummarizer is the Summarizer backed by a Bedrock foundation model.
+type BedrockSummarizer struct {
+	client  *bedrockruntime.Client
+	modelId string
+}
+
+func NewBedrockSummarizer(cfg aws.Config, appConfig Config) *BedrockSummarizer {
+	client := bedrockruntime.NewFromConfig(cfg, func(o *bedrockruntime.Options) {
+		o.BaseEndpoint = baseEndpoint(appConfig.BedrockEndpoint)
+	})
+	return &BedrockSummarizer{
+		client:  client,
+		modelId: appConfig.ModelId,
+	}
+}
+
+func (b *BedrockSummarizer) GenerateMovieSummary(ctx context.Context, movie Movie) (string, error) {
+	// Define inference parameters
+	inferenceConfig := &types.InferenceConfiguration{
+		MaxTokens: aws.Int32(500), // Limit response length
+	}
+
+	prompt := fmt.Sprintf("Provide a short summary of 100 words for the movie '%v', released in %d, which falls under the genre %v.", movie.Title, movie.ReleaseYear, strings.Join(movie.Genre, ", "))
+
+	// Create converse request for Messages API
+	converseRequest := &bedrockruntime.ConverseInput{
+		ModelId: aws.String(b.modelId),
+		Messages: []types.Message{{Role: types.ConversationRoleUser, Content: []types.ContentBlock{
+			&types.ContentBlockMemberText{Value: prompt},
+		}}},
+		System: []types.SystemContentBlock{
+			&types.SystemContentBlockMemberText{Value: "You are a helpful AI assistant that specializes in movie summaries in 100 words. Just return the summary."},
+		},
+		InferenceConfig: inferenceConfig,
+	}
+
+	output, err := b.client.Converse(ctx, converseRequest)
+	if err != nil {
+		return "", upstreamError("Bedrock", err)
+	}
+
+	message, ok := output.Output.(*types.ConverseOutputMemberMessage)
+	if !ok {
+		return "", upstreamError("Bedrock", errors.New("unexpected output type"))
+	}
+	outputValue := message.Value
+	if len(outputValue.Content) == 0 {
+		return "", upstreamError("Bedrock", errors.New("no summary returned"))
+
+	}
+	text, ok := outputValue.Content[0].(*types.ContentBlockMemberText)
+	if !ok || text.Value == "" {
+		return "", upstreamError("Bedrock", errors.New("no summary returned"))
+	}
+
+	return text.Value, nil
+}
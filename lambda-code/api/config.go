@@ -0,0 +1,201 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaults used when the matching environment variable is not set. They
+// describe the dev stack created by aws-infra.
+const (
+	DEFAULT_REGION              string        = "ap-south-1"
+	DEFAULT_TABLE_NAME          string        = "Movies"
+	DEFAULT_TITLES_TABLE_NAME   string        = "MovieTitles"
+	DEFAULT_SEARCH_TABLE_NAME   string        = "MovieSearchIndex"
+	DEFAULT_BUCKET_NAME         string        = "movies-api-data"
+	DEFAULT_IMAGE_PREFIX        string        = "images"
+	DEFAULT_UPLOAD_PREFIX       string        = "uploads"
+	DEFAULT_MODEL_ID            string        = "anthropic.claude-3-sonnet-20240229-v1:0"
+	DEFAULT_MAX_UPLOAD_BYTES    int64         = 10 << 20
+	DEFAULT_MAX_COVER_BYTES     int64         = 5 << 20
+	DEFAULT_MAX_COVER_DIMENSION int64         = 4096
+	DEFAULT_COVER_URL_TTL       time.Duration = time.Hour
+)
+
+// maxCoverUrlTTL is the longest validity SigV4 allows a presigned URL.
+const maxCoverUrlTTL = 7 * 24 * time.Hour
+
+// Config holds the settings that differ between the dev, staging and prod
+// stacks. It is read from the environment once per cold start.
+type Config struct {
+	Region          string
+	TableName       string
+	TitlesTableName string
+	SearchTableName string
+	BucketName      string
+	ImagePrefix     string
+	UploadPrefix    string
+	ModelId         string
+	MaxUploadBytes  int64
+	LogLevel        string
+
+	// Limits on uploaded cover images. MaxCoverDimension applies to the
+	// width and the height.
+	MaxCoverBytes     int64
+	MaxCoverDimension int64
+
+	// Optional endpoint overrides for running against DynamoDB Local, an S3
+	// compatible server or a fake Bedrock. Empty uses the AWS endpoints.
+	DynamoDBEndpoint string
+	S3Endpoint       string
+	BedrockEndpoint  string
+	// S3UsePathStyle addresses objects as endpoint/bucket/key, which most S3
+	// compatible servers require.
+	S3UsePathStyle bool
+
+	// MovieStore selects the MovieStore implementation, "dynamodb" or
+	// "memory" for local runs.
+	MovieStore string
+
+	// CoverUrlMode selects how cover URLs are rendered from the stored object
+	// keys: "s3" links to the bucket, "cdn" to CoverBaseUrl, e.g. a
+	// CloudFront distribution in front of the bucket, and "presigned" returns
+	// presigned GET URLs, valid for CoverUrlTTL, which keeps the bucket
+	// private.
+	CoverUrlMode string
+	CoverBaseUrl string
+	CoverUrlTTL  time.Duration
+}
+
+// LoadConfig reads the Config from the environment, falling back to the
+// defaults above, and reports every invalid value at once.
+//
+// The region is taken from REGION, which Terraform sets, and then from
+// AWS_REGION, which the Lambda runtime sets.
+func LoadConfig() (Config, error) {
+	cfg := Config{
+		Region:            firstEnv(DEFAULT_REGION, "REGION", "AWS_REGION"),
+		TableName:         firstEnv(DEFAULT_TABLE_NAME, "TABLE_NAME"),
+		TitlesTableName:   firstEnv(DEFAULT_TITLES_TABLE_NAME, "TITLES_TABLE_NAME"),
+		SearchTableName:   firstEnv(DEFAULT_SEARCH_TABLE_NAME, "SEARCH_TABLE_NAME"),
+		BucketName:        firstEnv(DEFAULT_BUCKET_NAME, "BUCKET_NAME"),
+		ImagePrefix:       strings.Trim(firstEnv(DEFAULT_IMAGE_PREFIX, "IMAGE_PREFIX"), "/"),
+		UploadPrefix:      strings.Trim(firstEnv(DEFAULT_UPLOAD_PREFIX, "UPLOAD_PREFIX"), "/"),
+		ModelId:           firstEnv(DEFAULT_MODEL_ID, "MODEL_ID"),
+		MaxUploadBytes:    DEFAULT_MAX_UPLOAD_BYTES,
+		MaxCoverBytes:     DEFAULT_MAX_COVER_BYTES,
+		MaxCoverDimension: DEFAULT_MAX_COVER_DIMENSION,
+		LogLevel:          firstEnv("info", "LOG_LEVEL"),
+		MovieStore:        firstEnv("dynamodb", "MOVIE_STORE"),
+		CoverUrlMode:      firstEnv("s3", "COVER_URL_MODE"),
+		CoverBaseUrl:      firstEnv("", "COVER_BASE_URL"),
+		CoverUrlTTL:       DEFAULT_COVER_URL_TTL,
+
+		DynamoDBEndpoint: firstEnv("", "DYNAMODB_ENDPOINT"),
+		S3Endpoint:       firstEnv("", "S3_ENDPOINT"),
+		BedrockEndpoint:  firstEnv("", "BEDROCK_ENDPOINT"),
+	}
+
+	var problems []string
+
+	endpoints := []struct{ name, value string }{
+		{"DYNAMODB_ENDPOINT", cfg.DynamoDBEndpoint},
+		{"S3_ENDPOINT", cfg.S3Endpoint},
+		{"BEDROCK_ENDPOINT", cfg.BedrockEndpoint},
+		{"COVER_BASE_URL", cfg.CoverBaseUrl},
+	}
+	for _, endpoint := range endpoints {
+		if endpoint.value == "" {
+			continue
+		}
+		if u, err := url.Parse(endpoint.value); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, fmt.Sprintf("%v must be an http or https URL, got %q", endpoint.name, endpoint.value))
+		}
+	}
+
+	if value := os.Getenv("S3_USE_PATH_STYLE"); value != "" {
+		usePathStyle, err := strconv.ParseBool(value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("S3_USE_PATH_STYLE must be true or false, got %q", value))
+		}
+		cfg.S3UsePathStyle = usePathStyle
+	}
+
+	limits := []struct {
+		name, unit string
+		value      *int64
+	}{
+		{"MAX_UPLOAD_BYTES", "bytes", &cfg.MaxUploadBytes},
+		{"MAX_COVER_BYTES", "bytes", &cfg.MaxCoverBytes},
+		{"MAX_COVER_DIMENSION", "pixels", &cfg.MaxCoverDimension},
+	}
+	for _, limit := range limits {
+		value := os.Getenv(limit.name)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed <= 0 {
+			problems = append(problems, fmt.Sprintf("%v must be a positive number of %v, got %q", limit.name, limit.unit, value))
+			continue
+		}
+		*limit.value = parsed
+	}
+
+	if value := os.Getenv("COVER_URL_TTL"); value != "" {
+		ttl, err := time.ParseDuration(value)
+		if err != nil || ttl < time.Second || ttl > maxCoverUrlTTL {
+			problems = append(problems, fmt.Sprintf("COVER_URL_TTL must be a duration between 1s and %v such as 15m, got %q", maxCoverUrlTTL, value))
+		}
+		cfg.CoverUrlTTL = ttl
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.LogLevel)); err != nil {
+		problems = append(problems, fmt.Sprintf("LOG_LEVEL must be one of debug, info, warn or error, got %q", cfg.LogLevel))
+	}
+
+	if cfg.MovieStore != "dynamodb" && cfg.MovieStore != "memory" {
+		problems = append(problems, fmt.Sprintf("MOVIE_STORE must be dynamodb or memory, got %q", cfg.MovieStore))
+	}
+
+	switch cfg.CoverUrlMode {
+	case "s3", "presigned":
+	case "cdn":
+		if cfg.CoverBaseUrl == "" {
+			problems = append(problems, "COVER_BASE_URL is required when COVER_URL_MODE is cdn")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("COVER_URL_MODE must be s3, cdn or presigned, got %q", cfg.CoverUrlMode))
+	}
+
+	if cfg.ImagePrefix == "" {
+		problems = append(problems, "IMAGE_PREFIX cannot be empty")
+	}
+	if cfg.UploadPrefix == "" {
+		problems = append(problems, "UPLOAD_PREFIX cannot be empty")
+	} else if cfg.UploadPrefix == cfg.ImagePrefix {
+		problems = append(problems, "UPLOAD_PREFIX must differ from IMAGE_PREFIX, uploads are public once under IMAGE_PREFIX")
+	}
+
+	if len(problems) > 0 {
+		return Config{}, fmt.Errorf("invalid configuration: %v", strings.Join(problems, "; "))
+	}
+	return cfg, nil
+}
+
+// firstEnv returns the first non-empty environment variable out of names, or
+// fallback if none is set.
+func firstEnv(fallback string, names ...string) string {
+	for _, name := range names {
+		if value := strings.TrimSpace(os.Getenv(name)); value != "" {
+			return value
+		}
+	}
+	return fallback
+}
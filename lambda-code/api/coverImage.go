@@ -0,0 +1,124 @@
+package api
+
+import (
+	"bytes"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"mime/multipart"
+
+	_ "golang.org/x/image/webp"
+)
+
+// coverTypes are the image formats accepted as covers, keyed by the format
+// name image.DecodeConfig reports.
+var coverTypes = map[string]coverType{
+	"jpeg": {"image/jpeg", ".jpg"},
+	"png":  {"image/png", ".png"},
+	"webp": {"image/webp", ".webp"},
+}
+
+type coverType struct {
+	contentType string
+	extension   string
+}
+
+func isCoverContentType(contentType string) bool {
+	for _, coverType := range coverTypes {
+		if coverType.contentType == contentType {
+			return true
+		}
+	}
+	return false
+}
+
+// CoverImage is an uploaded cover that has been checked to be a JPEG, PNG or
+// WebP image within the configured limits.
+type CoverImage struct {
+	Data []byte
+	// ContentType is sniffed from the data, the Content-Type and filename the
+	// client sent are ignored.
+	ContentType string
+	Extension   string
+	Width       int
+	Height      int
+
+	// image is the decoded cover, resized by resizeCover.
+	image image.Image
+}
+
+// sniffImageFormat identifies the cover formats by their magic bytes.
+func sniffImageFormat(data []byte) (string, bool) {
+	switch {
+	case bytes.HasPrefix(data, []byte("\xff\xd8\xff")):
+		return "jpeg", true
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return "png", true
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return "webp", true
+	}
+	return "", false
+}
+
+// readCover validates the uploaded coverImage file. It is rejected with 413
+// when larger than maxBytes and otherwise checked by decodeCover.
+func readCover(fileHeader *multipart.FileHeader, maxBytes int64, maxDimension int64) (*CoverImage, error) {
+	if fileHeader.Size > maxBytes {
+		return nil, newError(ErrPayloadTooLarge, "coverImage cannot be larger than %d bytes", maxBytes)
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, newError(ErrPayloadTooLarge, "coverImage cannot be larger than %d bytes", maxBytes)
+	}
+	return decodeCover(data, maxDimension)
+}
+
+// decodeCover fails validation unless data sniffs and decodes as one of
+// coverTypes no wider or taller than maxDimension pixels.
+func decodeCover(data []byte, maxDimension int64) (*CoverImage, error) {
+	var validation ValidationError
+	invalid := func(format string, args ...any) (*CoverImage, error) {
+		validation.Add("coverImage", format, args...)
+		return nil, validation.Err()
+	}
+
+	format, ok := sniffImageFormat(data)
+	if !ok {
+		return invalid("'coverImage' must be a JPEG, PNG or WebP image")
+	}
+
+	// The header is checked before decoding so an image claiming huge
+	// dimensions is refused without allocating its pixels.
+	config, decodedFormat, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || decodedFormat != format {
+		return invalid("'coverImage' is not a valid %v image", format)
+	}
+	if int64(config.Width) > maxDimension || int64(config.Height) > maxDimension {
+		return invalid("'coverImage' cannot be larger than %dx%d pixels, got %dx%d", maxDimension, maxDimension, config.Width, config.Height)
+	}
+
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return invalid("'coverImage' is not a valid %v image", format)
+	}
+
+	return &CoverImage{
+		Data:        data,
+		ContentType: coverTypes[format].contentType,
+		Extension:   coverTypes[format].extension,
+		Width:       config.Width,
+		Height:      config.Height,
+		image:       decoded,
+	}, nil
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"image"
+	"image/jpeg"
+	"mime/multipart"
+	"net/textproto"
+	"testing"
+)
+
+// fileHeader returns content as the coverImage file of a parsed form.
+func fileHeader(t *testing.T, content []byte) *multipart.FileHeader {
+	t.Helper()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", `form-data; name="coverImage"; filename="cover.gif"`)
+	header.Set("Content-Type", "image/gif")
+	part, _ := writer.CreatePart(header)
+	part.Write(content)
+	writer.Close()
+
+	form, err := multipart.NewReader(&body, writer.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatalf("ReadForm: %v", err)
+	}
+	return form.File["coverImage"][0]
+}
+
+func TestReadCover(t *testing.T) {
+	var jpegImage bytes.Buffer
+	jpeg.Encode(&jpegImage, image.NewGray(image.Rect(0, 0, 8, 4)), nil)
+
+	tests := []struct {
+		name            string
+		content         []byte
+		maxBytes        int64
+		wantErr         error
+		wantContentType string
+	}{
+		{name: "png", content: testPNG(10, 20), wantContentType: "image/png"},
+		{name: "jpeg despite the client's gif content type", content: jpegImage.Bytes(), wantContentType: "image/jpeg"},
+		{name: "pdf", content: []byte("%PDF-1.7\n"), wantErr: ErrValidation},
+		{name: "png magic without an image", content: []byte("\x89PNG\r\n\x1a\nnot really"), wantErr: ErrValidation},
+		{name: "too wide", content: testPNG(65, 10), wantErr: ErrValidation},
+		{name: "too many bytes", content: testPNG(10, 10), maxBytes: 16, wantErr: ErrPayloadTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maxBytes := cmp.Or(tt.maxBytes, 1<<20)
+			cover, err := readCover(fileHeader(t, tt.content), maxBytes, 64)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readCover: %v", err)
+			}
+			if cover.ContentType != tt.wantContentType || !bytes.Equal(cover.Data, tt.content) {
+				t.Errorf("cover = %v %d bytes, want %v", cover.ContentType, len(cover.Data), tt.wantContentType)
+			}
+		})
+	}
+}
+
+func TestResizeCover(t *testing.T) {
+	var original bytes.Buffer
+	jpeg.Encode(&original, image.NewRGBA(image.Rect(0, 0, 1000, 500)), nil)
+
+	cover, err := readCover(fileHeader(t, original.Bytes()), 1<<20, 4096)
+	if err != nil {
+		t.Fatalf("readCover: %v", err)
+	}
+	variants, err := resizeCover(cover)
+	if err != nil {
+		t.Fatalf("resizeCover: %v", err)
+	}
+
+	if len(variants) != len(coverWidths) {
+		t.Fatalf("got %d variants, want one per cover width", len(variants))
+	}
+	for i, variant := range variants {
+		config, format, err := image.DecodeConfig(bytes.NewReader(variant.Data))
+		if err != nil {
+			t.Fatalf("variant %d: %v", variant.Width, err)
+		}
+		if format != "jpeg" || variant.Extension != ".jpg" || config.Width != coverWidths[i] || config.Height != coverWidths[i]/2 {
+			t.Errorf("variant %d is a %dx%d %v, want %dx%d jpeg", variant.Width, config.Width, config.Height, format, coverWidths[i], coverWidths[i]/2)
+		}
+	}
+}
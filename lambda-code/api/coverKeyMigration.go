@@ -0,0 +1,130 @@
+package api
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// CoverKeyMigration reports what MigrateCoverKeys did. Migrated counts the
+// movies whose legacy attributes were rewritten, including those holding an
+// empty coverUrl that are only cleaned up.
+type CoverKeyMigration struct {
+	Scanned  int
+	Migrated int
+}
+
+// legacyCover holds the cover attributes written before covers were stored
+// by object key: the absolute URLs of the cover and of its variants.
+type legacyCover struct {
+	MovieId  string        `dynamodbav:"movieId"`
+	CoverUrl string        `dynamodbav:"coverUrl"`
+	Covers   CoverVariants `dynamodbav:"covers"`
+	CoverKey string        `dynamodbav:"coverKey"`
+}
+
+// MigrateCoverKeys replaces the coverUrl and covers URLs of movies written
+// before covers were stored by key with coverKey and coverKeys, the last
+// path segment of each URL. A movie that has been given a coverKey since is
+// only stripped of its legacy attributes, so it is safe to run more than
+// once. With dryRun the table is only read. It is run by
+// cmd/migrate-cover-keys.
+func (s *DynamoStore) MigrateCoverKeys(ctx context.Context, dryRun bool) (CoverKeyMigration, error) {
+	var migration CoverKeyMigration
+
+	filter := expression.Or(
+		expression.AttributeExists(expression.Name("coverUrl")),
+		expression.AttributeExists(expression.Name("covers")),
+	)
+	projection := expression.NamesList(
+		expression.Name("movieId"), expression.Name("coverUrl"), expression.Name("covers"), expression.Name("coverKey"),
+	)
+	expr, err := expression.NewBuilder().WithFilter(filter).WithProjection(projection).Build()
+	if err != nil {
+		return migration, err
+	}
+
+	paginator := dynamodb.NewScanPaginator(s.client, &dynamodb.ScanInput{
+		TableName:                 aws.String(s.tableName),
+		FilterExpression:          expr.Filter(),
+		ProjectionExpression:      expr.Projection(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return migration, upstreamError("DynamoDB", err)
+		}
+
+		var covers []legacyCover
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &covers); err != nil {
+			return migration, err
+		}
+		for _, cover := range covers {
+			migration.Scanned++
+			if dryRun {
+				continue
+			}
+			if err := s.migrateMovieCoverKeys(ctx, cover); err != nil {
+				return migration, err
+			}
+			migration.Migrated++
+		}
+	}
+	return migration, nil
+}
+
+// migratedCover derives the object keys of a legacy cover from its URLs.
+func migratedCover(cover legacyCover) storedCover {
+	return coverFromUrls(cover.CoverUrl, cover.Covers)
+}
+
+// migrateMovieCoverKeys removes the legacy cover attributes of a movie and,
+// unless it already has a coverKey, stores the keys derived from them. The
+// rendered URLs are unchanged so the version is not bumped.
+func (s *DynamoStore) migrateMovieCoverKeys(ctx context.Context, cover legacyCover) error {
+	update := expression.Remove(expression.Name("coverUrl")).Remove(expression.Name("covers"))
+	condition := expression.AttributeExists(expression.Name("movieId"))
+
+	if migrated := migratedCover(cover); cover.CoverKey == "" && migrated.Key != "" {
+		update = update.Set(expression.Name("coverKey"), expression.Value(migrated.Key))
+		if len(migrated.VariantKeys) > 0 {
+			update = update.Set(expression.Name("coverKeys"), expression.Value(migrated.VariantKeys))
+		}
+		// A cover uploaded concurrently wins over the legacy one.
+		condition = condition.And(expression.AttributeNotExists(expression.Name("coverKey")))
+	}
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]types.AttributeValue{
+			"movieId": &types.AttributeValueMemberS{Value: cover.MovieId},
+		},
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		// Deleted or given a new cover by a concurrent write in the meantime.
+		return nil
+	}
+	if err != nil {
+		return upstreamError("DynamoDB", err)
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package api
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestMigratedCover(t *testing.T) {
+	migrated := migratedCover(legacyCover{
+		MovieId:  "m1",
+		CoverUrl: "https://movies-api-data.s3.ap-south-1.amazonaws.com/images/m1.jpg",
+		Covers:   CoverVariants{"150": "https://movies-api-data.s3.ap-south-1.amazonaws.com/images/m1-150w.jpg"},
+	})
+	if migrated.Key != "m1.jpg" {
+		t.Errorf("key = %q, want m1.jpg", migrated.Key)
+	}
+	if want := (CoverVariants{"150": "m1-150w.jpg"}); !maps.Equal(migrated.VariantKeys, want) {
+		t.Errorf("variant keys = %v, want %v", migrated.VariantKeys, want)
+	}
+
+	if empty := migratedCover(legacyCover{MovieId: "m2"}); empty.Key != "" || empty.VariantKeys != nil {
+		t.Errorf("migrated an empty coverUrl to %+v", empty)
+	}
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"path"
+	"slices"
+	"strconv"
+
+	"golang.org/x/image/draw"
+)
+
+// coverWidths are the widths, in pixels, covers are resized to on upload so
+// clients can fetch a thumbnail instead of the original.
+var coverWidths = []int{150, 300, 600}
+
+const coverJpegQuality = 85
+
+// CoverVariants maps a width from coverWidths, as a string, to the object key
+// of the cover resized to that width, or to its URL once rendered.
+type CoverVariants map[string]string
+
+// coverVariant is a resized cover ready to be uploaded.
+type coverVariant struct {
+	Width       int
+	Data        []byte
+	ContentType string
+	Extension   string
+}
+
+// coverKey is the object key of an uploaded cover of movieId:
+// {movieId}-{coverId}{extension}. Every upload gets a new coverId, so it
+// never overwrites the objects of the cover the movie currently points at.
+func coverKey(movieId string, coverId string, extension string) string {
+	return fmt.Sprintf("%v-%v%v", movieId, coverId, extension)
+}
+
+// variantKey is the object key of the variant of a cover resized to width,
+// next to the original: {movieId}-{coverId}-{width}w{extension}.
+func variantKey(movieId string, coverId string, width int, extension string) string {
+	return fmt.Sprintf("%v-%v-%dw%v", movieId, coverId, width, extension)
+}
+
+// resizeCover scales the cover down to each of coverWidths narrower than it,
+// keeping the aspect ratio. PNG covers stay PNG to keep their transparency;
+// JPEG and WebP covers are encoded as JPEG, as Go has no WebP encoder.
+func resizeCover(cover *CoverImage) ([]coverVariant, error) {
+	var variants []coverVariant
+	for _, width := range coverWidths {
+		if width >= cover.Width {
+			continue
+		}
+		height := max(1, (cover.Height*width+cover.Width/2)/cover.Width)
+
+		resized := image.NewRGBA(image.Rect(0, 0, width, height))
+		draw.CatmullRom.Scale(resized, resized.Bounds(), cover.image, cover.image.Bounds(), draw.Src, nil)
+
+		variant := coverVariant{Width: width}
+		var buf bytes.Buffer
+		if cover.ContentType == "image/png" {
+			if err := png.Encode(&buf, resized); err != nil {
+				return nil, err
+			}
+			variant.ContentType, variant.Extension = "image/png", ".png"
+		} else {
+			if err := jpeg.Encode(&buf, resized, &jpeg.Options{Quality: coverJpegQuality}); err != nil {
+				return nil, err
+			}
+			variant.ContentType, variant.Extension = "image/jpeg", ".jpg"
+		}
+		variant.Data = buf.Bytes()
+		variants = append(variants, variant)
+	}
+	return variants, nil
+}
+
+// storedCover is a cover as uploaded to the CoverStore: the keys of the
+// original and of its resized variants.
+type storedCover struct {
+	Key         string
+	VariantKeys CoverVariants
+}
+
+// movieCover is the cover of movie. Movies written before covers were
+// stored by key have it derived from their legacy URLs instead.
+func movieCover(movie Movie) storedCover {
+	if movie.CoverKey == "" && movie.LegacyCoverUrl != "" {
+		return coverFromUrls(movie.LegacyCoverUrl, movie.LegacyCovers)
+	}
+	return storedCover{Key: movie.CoverKey, VariantKeys: movie.CoverKeys}
+}
+
+// coverFromUrls derives the object keys of a cover from the absolute URLs
+// stored before covers were stored by key, the last path segment of each.
+func coverFromUrls(coverUrl string, covers CoverVariants) storedCover {
+	var cover storedCover
+	if coverUrl != "" {
+		cover.Key = path.Base(coverUrl)
+	}
+	for width, url := range covers {
+		if cover.VariantKeys == nil {
+			cover.VariantKeys = CoverVariants{}
+		}
+		cover.VariantKeys[width] = path.Base(url)
+	}
+	return cover
+}
+
+// objectKeys returns the keys of every object making up the cover.
+func (c storedCover) objectKeys() []string {
+	var keys []string
+	if c.Key != "" {
+		keys = append(keys, c.Key)
+	}
+	for _, width := range coverWidths {
+		if key, ok := c.VariantKeys[strconv.Itoa(width)]; ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// staleKeys returns the keys of c that are not reused by replacement.
+func (c storedCover) staleKeys(replacement storedCover) []string {
+	keep := replacement.objectKeys()
+	return slices.DeleteFunc(c.objectKeys(), func(key string) bool {
+		return slices.Contains(keep, key)
+	})
+}
@@ -0,0 +1,719 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	// releaseYearIndex is a sparse GSI keyed on entityType with releaseYear as
+	// its sort key. Every movie item carries entityType=movie, which puts the
+	// whole catalogue in one partition ordered by year, so both single years
+	// and year ranges are answered by a single Query.
+	releaseYearIndex = "releaseYear-index"
+	entityTypeAttr   = "entityType"
+	movieEntityType  = "movie"
+
+	// The titles table holds one item per normalized title pointing at the
+	// movie that owns it. It is written in the same transaction as the movie
+	// so two movies can never end up sharing a title.
+	normalizedTitleAttr = "normalizedTitle"
+
+	versionAttr = "version"
+
+	// A list page reads at most readPageBudget Scan or Query pages of
+	// readPageSize items, however selective its filter, and returns what it
+	// found with a cursor at the point the read stopped, so a page may be
+	// short or empty and still have a next one.
+	readPageSize   = 100
+	readPageBudget = 10
+)
+
+// DynamoDBAPI is the part of the DynamoDB client DynamoStore uses, so tests
+// can substitute a fake.
+type DynamoDBAPI interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
+	BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error)
+	BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error)
+}
+
+// DynamoStore is the MovieStore backed by the Movies DynamoDB table.
+type DynamoStore struct {
+	client          DynamoDBAPI
+	tableName       string
+	titlesTableName string
+	searchTableName string
+}
+
+func NewDynamoStore(cfg aws.Config, appConfig Config) *DynamoStore {
+	client := dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) {
+		o.BaseEndpoint = baseEndpoint(appConfig.DynamoDBEndpoint)
+	})
+	return &DynamoStore{
+		client:          client,
+		tableName:       appConfig.TableName,
+		titlesTableName: appConfig.TitlesTableName,
+		searchTableName: appConfig.SearchTableName,
+	}
+}
+
+// GetAllMovies scans the table, or queries the releaseYear index when the
+// filter bounds the release year or the list is sorted by it, which returns
+// the movies in year order.
+func (s *DynamoStore) GetAllMovies(ctx context.Context, limit int32, cursor string, filter MovieFilter, sort string, fields []string) ([]Movie, string, error) {
+	byYear := filter.hasYear() || sort != ""
+	startKey, err := decodeCursor(cursor, byYear)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var read readPage
+	if byYear {
+		read, err = s.yearPages(filter, strings.HasPrefix(sort, "-"), fields, max(limit, readPageSize))
+	} else {
+		read, err = s.scanPages(filter, fields, max(limit, readPageSize))
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	// A filtered page can come back short, keep reading until the page is
+	// full, the movies are exhausted or the budget is spent.
+	var movies []Movie
+	lastKey := startKey
+	for pages := 1; ; pages++ {
+		items, nextKey, err := read(ctx, lastKey)
+		if err != nil {
+			return nil, "", upstreamError("DynamoDB", err)
+		}
+
+		var page []Movie
+		if err := attributevalue.UnmarshalListOfMaps(items, &page); err != nil {
+			return nil, "", err
+		}
+		movies = append(movies, slices.DeleteFunc(page, func(movie Movie) bool {
+			return !filter.matches(movie)
+		})...)
+		lastKey = nextKey
+
+		if len(movies) >= int(limit) || len(lastKey) == 0 || pages == readPageBudget {
+			break
+		}
+	}
+
+	if len(movies) > int(limit) {
+		// Resume after the last movie returned rather than the last one
+		// read.
+		movies = movies[:limit]
+		lastKey = listKey(movies[limit-1], byYear)
+	}
+
+	nextCursor, err := encodeCursor(lastKey)
+	if err != nil {
+		return nil, "", err
+	}
+	return movies, nextCursor, nil
+}
+
+// readPage reads the page of a list following startKey, returning its items
+// and the LastEvaluatedKey.
+type readPage func(ctx context.Context, startKey map[string]types.AttributeValue) ([]map[string]types.AttributeValue, map[string]types.AttributeValue, error)
+
+// scanPages reads the movies matching filter from the table.
+func (s *DynamoStore) scanPages(filter MovieFilter, fields []string, pageSize int32) (readPage, error) {
+	input := dynamodb.ScanInput{
+		TableName: aws.String(s.tableName),
+		Limit:     aws.Int32(pageSize),
+	}
+	if builder, ok := listBuilder(expression.NewBuilder(), filter, true, fields); ok {
+		expr, err := builder.Build()
+		if err != nil {
+			return nil, err
+		}
+		input.FilterExpression = expr.Filter()
+		input.ProjectionExpression = expr.Projection()
+		input.ExpressionAttributeNames = expr.Names()
+		input.ExpressionAttributeValues = expr.Values()
+	}
+
+	return func(ctx context.Context, startKey map[string]types.AttributeValue) ([]map[string]types.AttributeValue, map[string]types.AttributeValue, error) {
+		input.ExclusiveStartKey = startKey
+		result, err := s.client.Scan(ctx, &input)
+		if err != nil {
+			return nil, nil, err
+		}
+		return result.Items, result.LastEvaluatedKey, nil
+	}, nil
+}
+
+// yearPages reads the movies matching filter from the releaseYear index, in
+// year order, latest first when descending is set.
+func (s *DynamoStore) yearPages(filter MovieFilter, descending bool, fields []string, pageSize int32) (readPage, error) {
+	builder, _ := listBuilder(expression.NewBuilder().WithKeyCondition(yearKeyCondition(filter)), filter, false, fields)
+	expr, err := builder.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	input := dynamodb.QueryInput{
+		TableName:                 aws.String(s.tableName),
+		IndexName:                 aws.String(releaseYearIndex),
+		Limit:                     aws.Int32(pageSize),
+		ScanIndexForward:          aws.Bool(!descending),
+		KeyConditionExpression:    expr.KeyCondition(),
+		FilterExpression:          expr.Filter(),
+		ProjectionExpression:      expr.Projection(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}
+
+	return func(ctx context.Context, startKey map[string]types.AttributeValue) ([]map[string]types.AttributeValue, map[string]types.AttributeValue, error) {
+		input.ExclusiveStartKey = startKey
+		result, err := s.client.Query(ctx, &input)
+		if err != nil {
+			return nil, nil, err
+		}
+		return result.Items, result.LastEvaluatedKey, nil
+	}, nil
+}
+
+// yearKeyCondition selects the movies within the release years of filter
+// from the releaseYear index, or all of them when filter has no bounds.
+func yearKeyCondition(filter MovieFilter) expression.KeyConditionBuilder {
+	yearFrom, yearTo := filter.YearFrom, filter.YearTo
+	keyEx := expression.Key(entityTypeAttr).Equal(expression.Value(movieEntityType))
+	switch {
+	case yearFrom != 0 && yearFrom == yearTo:
+		keyEx = keyEx.And(expression.Key("releaseYear").Equal(expression.Value(yearFrom)))
+	case yearFrom != 0 && yearTo != 0:
+		keyEx = keyEx.And(expression.Key("releaseYear").Between(expression.Value(yearFrom), expression.Value(yearTo)))
+	case yearFrom != 0:
+		keyEx = keyEx.And(expression.Key("releaseYear").GreaterThanEqual(expression.Value(yearFrom)))
+	case yearTo != 0:
+		keyEx = keyEx.And(expression.Key("releaseYear").LessThanEqual(expression.Value(yearTo)))
+	}
+	return keyEx
+}
+
+// listKey is the key a list resumes after movie from: the table key, or the
+// index key when the list queries the releaseYear index.
+func listKey(movie Movie, byYear bool) map[string]types.AttributeValue {
+	key := map[string]types.AttributeValue{
+		"movieId": &types.AttributeValueMemberS{Value: movie.MovieId},
+	}
+	if byYear {
+		key[entityTypeAttr] = &types.AttributeValueMemberS{Value: movieEntityType}
+		key["releaseYear"] = &types.AttributeValueMemberN{Value: strconv.Itoa(int(movie.ReleaseYear))}
+	}
+	return key
+}
+
+// listBuilder adds the FilterExpression for filter and the
+// ProjectionExpression for fields to builder. ok is false when neither is
+// needed.
+func listBuilder(builder expression.Builder, filter MovieFilter, withYear bool, fields []string) (expression.Builder, bool) {
+	condition, ok := filterCondition(filter, withYear)
+	if ok {
+		builder = builder.WithFilter(condition)
+	}
+	if len(fields) > 0 {
+		var names []expression.NameBuilder
+		for _, field := range fields {
+			for _, attribute := range fieldAttributes(field) {
+				names = append(names, expression.Name(attribute))
+			}
+		}
+		builder = builder.WithProjection(expression.NamesList(names[0], names[1:]...))
+		ok = true
+	}
+	return builder, ok
+}
+
+// fieldAttributes are the attributes a movie field is read from. The cover
+// URLs are rendered from the stored object keys, or from the legacy URLs of
+// movies written before, every other field is stored under its own name.
+func fieldAttributes(field string) []string {
+	switch field {
+	case "coverUrl":
+		return []string{"coverKey", "coverUrl"}
+	case "covers":
+		return []string{"coverKeys", "covers"}
+	}
+	return []string{field}
+}
+
+// filterCondition is the FilterExpression for filter, with the release year
+// bounds only when withYear is set as a Query on the releaseYear index puts
+// them in the key condition instead. The title prefix is compared on the
+// normalized title, which is not stored, so it is left to
+// MovieFilter.matches. ok is false when nothing is filtered.
+func filterCondition(filter MovieFilter, withYear bool) (condition expression.ConditionBuilder, ok bool) {
+	and := func(c expression.ConditionBuilder) {
+		if ok {
+			condition = condition.And(c)
+		} else {
+			condition, ok = c, true
+		}
+	}
+	// present matches an attribute holding a non-empty string, which is how
+	// the seeded items store a missing cover or summary.
+	present := func(name string, want bool) expression.ConditionBuilder {
+		if want {
+			return expression.Name(name).Size().GreaterThan(expression.Value(0))
+		}
+		return expression.Or(
+			expression.AttributeNotExists(expression.Name(name)),
+			expression.Name(name).Size().Equal(expression.Value(0)),
+		)
+	}
+
+	if filter.Genre != "" {
+		// contains matches a member of the genre string set, and a substring
+		// of the genre strings written before the migration.
+		and(expression.Contains(expression.Name("genre"), filter.Genre))
+	}
+	if withYear && filter.YearFrom != 0 {
+		and(expression.Name("releaseYear").GreaterThanEqual(expression.Value(filter.YearFrom)))
+	}
+	if withYear && filter.YearTo != 0 {
+		and(expression.Name("releaseYear").LessThanEqual(expression.Value(filter.YearTo)))
+	}
+	if filter.HasCover != nil {
+		// Movies written before covers were stored by key hold a coverUrl.
+		if *filter.HasCover {
+			and(expression.Or(present("coverKey", true), present("coverUrl", true)))
+		} else {
+			and(present("coverKey", false).And(present("coverUrl", false)))
+		}
+	}
+	if filter.HasSummary != nil {
+		and(present("generatedSummary", *filter.HasSummary))
+	}
+	return condition, ok
+}
+
+func (s *DynamoStore) CountGenres(ctx context.Context) (map[string]int, error) {
+	paginator := dynamodb.NewScanPaginator(s.client, &dynamodb.ScanInput{
+		TableName:            aws.String(s.tableName),
+		ProjectionExpression: aws.String("genre"),
+	})
+
+	counts := map[string]int{}
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, upstreamError("DynamoDB", err)
+		}
+
+		var page []Movie
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &page); err != nil {
+			return nil, err
+		}
+		for _, movie := range page {
+			for _, genre := range movie.Genre {
+				counts[genre]++
+			}
+		}
+	}
+	return counts, nil
+}
+
+// encodeCursor turns a LastEvaluatedKey into an opaque cursor string that
+// clients can hand back to fetch the next page. An empty key means there are
+// no more pages and yields an empty cursor.
+func encodeCursor(lastKey map[string]types.AttributeValue) (string, error) {
+	if len(lastKey) == 0 {
+		return "", nil
+	}
+
+	var key map[string]any
+	if err := attributevalue.UnmarshalMap(lastKey, &key); err != nil {
+		return "", err
+	}
+
+	keyJson, err := json.Marshal(key)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(keyJson), nil
+}
+
+// decodeCursor reverses encodeCursor. An empty cursor starts from the
+// beginning. The cursor is handed back by clients, so anything but the key
+// listKey would return, a movieId string plus the releaseYear index key when
+// byYear is set, is rejected before it reaches DynamoDB.
+func decodeCursor(cursor string, byYear bool) (map[string]types.AttributeValue, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	keyJson, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, badRequest("invalid cursor")
+	}
+
+	var key map[string]any
+	if err := json.Unmarshal(keyJson, &key); err != nil {
+		return nil, badRequest("invalid cursor")
+	}
+	movieId, ok := key["movieId"].(string)
+	if !ok || movieId == "" {
+		return nil, badRequest("invalid cursor")
+	}
+	if !byYear {
+		if len(key) != 1 {
+			return nil, badRequest("invalid cursor")
+		}
+		return listKey(Movie{MovieId: movieId}, false), nil
+	}
+
+	releaseYear, ok := key["releaseYear"].(float64)
+	if len(key) != 3 || key[entityTypeAttr] != movieEntityType || !ok || releaseYear != math.Trunc(releaseYear) ||
+		releaseYear < 0 || releaseYear > math.MaxUint16 {
+		return nil, badRequest("invalid cursor")
+	}
+	return listKey(Movie{MovieId: movieId, ReleaseYear: uint16(releaseYear)}, true), nil
+}
+
+func (s *DynamoStore) UpdateMovieSummary(ctx context.Context, movieId string, summary string) error {
+	updateExpr := expression.Set(expression.Name("generatedSummary"), expression.Value(summary))
+	updateExpr.Add(expression.Name(versionAttr), expression.Value(1))
+	// Without the condition a movie deleted while its summary was generated
+	// would be recreated holding only the summary.
+	condition := expression.AttributeExists(expression.Name("movieId"))
+	expr, err := expression.NewBuilder().WithUpdate(updateExpr).WithCondition(condition).Build()
+
+	if err != nil {
+		return err
+	} else {
+		_, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName: aws.String(s.tableName),
+			Key: map[string]types.AttributeValue{
+				"movieId": &types.AttributeValueMemberS{Value: movieId},
+			},
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+			UpdateExpression:          expr.Update(),
+			ConditionExpression:       expr.Condition(),
+		})
+
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			return ErrMovieNotFound
+		}
+		if err != nil {
+			return upstreamError("DynamoDB", err)
+		}
+		return nil
+	}
+}
+
+func (s *DynamoStore) GetMovieById(ctx context.Context, movieId string) (Movie, error) {
+	result, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]types.AttributeValue{
+			"movieId": &types.AttributeValueMemberS{
+				Value: movieId,
+			},
+		},
+	})
+
+	if err != nil {
+		return Movie{}, upstreamError("DynamoDB", err)
+	}
+
+	if len(result.Item) == 0 {
+		return Movie{}, ErrMovieNotFound
+	}
+
+	var movie Movie
+	if err := attributevalue.UnmarshalMap(result.Item, &movie); err != nil {
+		return Movie{}, err
+	}
+
+	return movie, nil
+}
+
+// GetMoviesByIds reads the movies with BatchGetItem, batchGetSize keys at a
+// time, retrying unprocessed keys with exponential backoff.
+func (s *DynamoStore) GetMoviesByIds(ctx context.Context, movieIds []string) ([]Movie, error) {
+	var movies []Movie
+	for batch := range slices.Chunk(movieIds, batchGetSize) {
+		keys := make([]map[string]types.AttributeValue, len(batch))
+		for i, movieId := range batch {
+			keys[i] = map[string]types.AttributeValue{
+				"movieId": &types.AttributeValueMemberS{Value: movieId},
+			}
+		}
+		pending := map[string]types.KeysAndAttributes{s.tableName: {Keys: keys}}
+
+		for attempt := 0; len(pending) > 0; attempt++ {
+			if attempt == maxBatchAttempts {
+				return nil, upstreamError("DynamoDB", errors.New("batch get keys left unprocessed"))
+			}
+			if attempt > 0 {
+				if err := sleep(ctx, backoff(attempt)); err != nil {
+					return nil, err
+				}
+			}
+
+			result, err := s.client.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{RequestItems: pending})
+			if err != nil {
+				return nil, upstreamError("DynamoDB", err)
+			}
+
+			var page []Movie
+			if err := attributevalue.UnmarshalListOfMaps(result.Responses[s.tableName], &page); err != nil {
+				return nil, err
+			}
+			movies = append(movies, page...)
+			pending = result.UnprocessedKeys
+		}
+	}
+	return movies, nil
+}
+
+func (s *DynamoStore) DeleteMovieById(ctx context.Context, movieId string, expectedVersion *int64) (Movie, error) {
+	movie, err := s.GetMovieById(ctx, movieId)
+	if err != nil {
+		return Movie{}, err
+	}
+
+	if expectedVersion != nil && *expectedVersion != movie.Version {
+		return Movie{}, ErrVersionMismatch
+	}
+
+	condition := expression.AttributeExists(expression.Name("movieId")).And(versionCondition(movie.Version))
+	expr, err := expression.NewBuilder().WithCondition(condition).Build()
+	if err != nil {
+		return Movie{}, err
+	}
+
+	_, err = s.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{Delete: &types.Delete{
+				TableName: aws.String(s.tableName),
+				Key: map[string]types.AttributeValue{
+					"movieId": &types.AttributeValueMemberS{
+						Value: movieId,
+					},
+				},
+				ConditionExpression:       expr.Condition(),
+				ExpressionAttributeNames:  expr.Names(),
+				ExpressionAttributeValues: expr.Values(),
+			}},
+			s.deleteTitle(movie.Title, movieId),
+		},
+	})
+
+	if err != nil {
+		if conditionFailed(err, 0) && expectedVersion != nil {
+			return Movie{}, ErrVersionMismatch
+		}
+		if conditionFailed(err, 0) || conditionFailed(err, 1) {
+			return Movie{}, errConcurrentUpdate
+		}
+		return Movie{}, upstreamError("DynamoDB", err)
+	}
+
+	return movie, nil
+}
+
+func (s *DynamoStore) AddMovie(ctx context.Context, movie Movie) error {
+	movie.Version = 1
+	item, err := attributevalue.MarshalMap(movie)
+
+	if err != nil {
+		return err
+	}
+	item[entityTypeAttr] = &types.AttributeValueMemberS{Value: movieEntityType}
+
+	_, err = s.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{Put: &types.Put{
+				TableName:           aws.String(s.tableName),
+				Item:                item,
+				ConditionExpression: aws.String("attribute_not_exists(movieId)"),
+			}},
+			s.putTitle(movie.Title, movie.MovieId),
+		},
+	})
+
+	if err != nil {
+		if conditionFailed(err, 1) {
+			return ErrTitleExists
+		}
+		return upstreamError("DynamoDB", err)
+	}
+
+	return nil
+}
+
+// putTitle claims title for movieId, failing the transaction if another movie
+// already holds it.
+func (s *DynamoStore) putTitle(title string, movieId string) types.TransactWriteItem {
+	return types.TransactWriteItem{Put: &types.Put{
+		TableName: aws.String(s.titlesTableName),
+		Item: map[string]types.AttributeValue{
+			normalizedTitleAttr: &types.AttributeValueMemberS{Value: normalizeTitle(title)},
+			"movieId":           &types.AttributeValueMemberS{Value: movieId},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(#title)"),
+		ExpressionAttributeNames: map[string]string{
+			"#title": normalizedTitleAttr,
+		},
+	}}
+}
+
+// deleteTitle releases title if it is held by movieId. A missing record is not
+// an error so movies written before the titles table existed can still change.
+func (s *DynamoStore) deleteTitle(title string, movieId string) types.TransactWriteItem {
+	return types.TransactWriteItem{Delete: &types.Delete{
+		TableName: aws.String(s.titlesTableName),
+		Key: map[string]types.AttributeValue{
+			normalizedTitleAttr: &types.AttributeValueMemberS{Value: normalizeTitle(title)},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(#title) OR movieId = :movieId"),
+		ExpressionAttributeNames: map[string]string{
+			"#title": normalizedTitleAttr,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":movieId": &types.AttributeValueMemberS{Value: movieId},
+		},
+	}}
+}
+
+// versionCondition requires the item to still be at version. Items written
+// before versioning have no version attribute and are treated as version 0.
+func versionCondition(version int64) expression.ConditionBuilder {
+	if version == 0 {
+		return expression.AttributeNotExists(expression.Name(versionAttr))
+	}
+	return expression.Name(versionAttr).Equal(expression.Value(version))
+}
+
+// conditionFailed reports whether err is a cancelled transaction whose item
+// at index failed its condition check.
+func conditionFailed(err error, index int) bool {
+	var cancelled *types.TransactionCanceledException
+	if !errors.As(err, &cancelled) || index >= len(cancelled.CancellationReasons) {
+		return false
+	}
+	return aws.ToString(cancelled.CancellationReasons[index].Code) == "ConditionalCheckFailed"
+}
+
+func (s *DynamoStore) UpdateMovieById(ctx context.Context, movieId string, movie Movie, expectedVersion *int64) (Movie, error) {
+	return s.PatchMovieById(ctx, movieId, replacePatch(movie), expectedVersion)
+}
+
+func (s *DynamoStore) PatchMovieById(ctx context.Context, movieId string, patch MoviePatch, expectedVersion *int64) (Movie, error) {
+	current, err := s.GetMovieById(ctx, movieId)
+	if err != nil {
+		return Movie{}, err
+	}
+
+	if expectedVersion != nil && *expectedVersion != current.Version {
+		return Movie{}, ErrVersionMismatch
+	}
+
+	if patch.isEmpty() {
+		return current, nil
+	}
+
+	// Backfills the GSI partition key on items written before the index existed.
+	updateExpr := expression.Set(expression.Name(entityTypeAttr), expression.Value(movieEntityType))
+	updateExpr.Add(expression.Name(versionAttr), expression.Value(1))
+
+	if patch.Title != nil {
+		updateExpr.Set(expression.Name("title"), expression.Value(*patch.Title))
+	}
+	if patch.ReleaseYear != nil {
+		updateExpr.Set(expression.Name("releaseYear"), expression.Value(*patch.ReleaseYear))
+	}
+	if patch.Genre != nil {
+		updateExpr.Set(expression.Name("genre"), expression.Value(*patch.Genre))
+	}
+	if patch.CoverKey != nil || patch.RemoveCover {
+		updateExpr.Remove(expression.Name("coverUrl"))
+		updateExpr.Remove(expression.Name("covers"))
+	}
+	if patch.CoverKey != nil {
+		updateExpr.Set(expression.Name("coverKey"), expression.Value(*patch.CoverKey))
+		if len(patch.CoverKeys) > 0 {
+			updateExpr.Set(expression.Name("coverKeys"), expression.Value(patch.CoverKeys))
+		} else {
+			updateExpr.Remove(expression.Name("coverKeys"))
+		}
+	}
+	if patch.GeneratedSummary != nil {
+		updateExpr.Set(expression.Name("generatedSummary"), expression.Value(*patch.GeneratedSummary))
+	}
+	if patch.RemoveCover {
+		updateExpr.Remove(expression.Name("coverKey"))
+		updateExpr.Remove(expression.Name("coverKeys"))
+	}
+	if patch.RemoveGeneratedSummary {
+		updateExpr.Remove(expression.Name("generatedSummary"))
+	}
+
+	// The item must still be the version we read, otherwise the uniqueness
+	// records below could be moved away from the wrong title.
+	expr, err := expression.NewBuilder().WithUpdate(updateExpr).WithCondition(versionCondition(current.Version)).Build()
+	if err != nil {
+		return Movie{}, err
+	}
+
+	transactItems := []types.TransactWriteItem{
+		{Update: &types.Update{
+			TableName: aws.String(s.tableName),
+			Key: map[string]types.AttributeValue{
+				"movieId": &types.AttributeValueMemberS{Value: movieId},
+			},
+			ConditionExpression:       expr.Condition(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+			UpdateExpression:          expr.Update(),
+		}},
+	}
+
+	if patch.Title != nil && normalizeTitle(current.Title) != normalizeTitle(*patch.Title) {
+		transactItems = append(transactItems,
+			s.deleteTitle(current.Title, movieId),
+			s.putTitle(*patch.Title, movieId),
+		)
+	}
+
+	_, err = s.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: transactItems,
+	})
+
+	if err != nil {
+		switch {
+		case conditionFailed(err, 2):
+			return Movie{}, ErrTitleExists
+		case conditionFailed(err, 0) && expectedVersion != nil:
+			return Movie{}, ErrVersionMismatch
+		case conditionFailed(err, 0), conditionFailed(err, 1):
+			return Movie{}, errConcurrentUpdate
+		}
+		return Movie{}, upstreamError("DynamoDB", err)
+	}
+
+	return patch.apply(current), nil
+}
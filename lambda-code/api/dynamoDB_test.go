@@ -0,0 +1,780 @@
+package api
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// fakeDynamoDB is a DynamoDBAPI serving GetItem and BatchGetItem from movies
+// and Scan and Query from the pages queued per table. Writes are recorded,
+// not applied, and fail with the errors set.
+type fakeDynamoDB struct {
+	t       *testing.T
+	movies  map[string]Movie
+	scans   map[string][]*dynamodb.ScanOutput
+	queries map[string][]*dynamodb.QueryOutput
+	// unprocessed is how many BatchGetItem calls leave their last key
+	// unprocessed.
+	unprocessed   int
+	transactErr   error
+	updateErr     error
+	batchWriteErr error
+
+	scanInputs     []dynamodb.ScanInput
+	queryInputs    []dynamodb.QueryInput
+	updateInputs   []*dynamodb.UpdateItemInput
+	transactInputs []*dynamodb.TransactWriteItemsInput
+	batchGetCalls  int
+	// written and deleted hold the search entries written, as "token movieId".
+	written []string
+	deleted []string
+}
+
+const (
+	testTable       = "Movies"
+	testTitlesTable = "MovieTitles"
+	testSearchTable = "MovieSearchIndex"
+)
+
+func newFakeDynamoStore(t *testing.T, movies ...Movie) (*DynamoStore, *fakeDynamoDB) {
+	fake := &fakeDynamoDB{
+		t:       t,
+		movies:  map[string]Movie{},
+		scans:   map[string][]*dynamodb.ScanOutput{},
+		queries: map[string][]*dynamodb.QueryOutput{},
+	}
+	for _, movie := range movies {
+		fake.movies[movie.MovieId] = movie
+	}
+	return &DynamoStore{
+		client:          fake,
+		tableName:       testTable,
+		titlesTableName: testTitlesTable,
+		searchTableName: testSearchTable,
+	}, fake
+}
+
+func (f *fakeDynamoDB) item(movie Movie) map[string]types.AttributeValue {
+	f.t.Helper()
+	item, err := attributevalue.MarshalMap(movie)
+	if err != nil {
+		f.t.Fatalf("marshal %s: %v", movie.MovieId, err)
+	}
+	return item
+}
+
+// items marshals values, movies or search entries, into the items of a page.
+func (f *fakeDynamoDB) items(values ...any) []map[string]types.AttributeValue {
+	f.t.Helper()
+	items := make([]map[string]types.AttributeValue, len(values))
+	for i, value := range values {
+		item, err := attributevalue.MarshalMap(value)
+		if err != nil {
+			f.t.Fatalf("marshal %v: %v", value, err)
+		}
+		items[i] = item
+	}
+	return items
+}
+
+func movieKey(movieId string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{"movieId": &types.AttributeValueMemberS{Value: movieId}}
+}
+
+func keyMovieId(key map[string]types.AttributeValue) string {
+	if id, ok := key["movieId"].(*types.AttributeValueMemberS); ok {
+		return id.Value
+	}
+	return ""
+}
+
+func (f *fakeDynamoDB) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	movie, ok := f.movies[keyMovieId(params.Key)]
+	if !ok {
+		return &dynamodb.GetItemOutput{}, nil
+	}
+	return &dynamodb.GetItemOutput{Item: f.item(movie)}, nil
+}
+
+func (f *fakeDynamoDB) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	f.updateInputs = append(f.updateInputs, params)
+	if f.updateErr != nil {
+		return nil, f.updateErr
+	}
+	output := &dynamodb.UpdateItemOutput{}
+	if movie, ok := f.movies[keyMovieId(params.Key)]; ok {
+		output.Attributes = f.item(movie)
+	}
+	return output, nil
+}
+
+func (f *fakeDynamoDB) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	f.scanInputs = append(f.scanInputs, *params)
+	table := aws.ToString(params.TableName)
+	if len(f.scans[table]) == 0 {
+		return &dynamodb.ScanOutput{}, nil
+	}
+	page := f.scans[table][0]
+	f.scans[table] = f.scans[table][1:]
+	return page, nil
+}
+
+func (f *fakeDynamoDB) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	f.queryInputs = append(f.queryInputs, *params)
+	table := aws.ToString(params.TableName)
+	if len(f.queries[table]) == 0 {
+		return &dynamodb.QueryOutput{}, nil
+	}
+	page := f.queries[table][0]
+	f.queries[table] = f.queries[table][1:]
+	return page, nil
+}
+
+func (f *fakeDynamoDB) TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	f.transactInputs = append(f.transactInputs, params)
+	if f.transactErr != nil {
+		return nil, f.transactErr
+	}
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+func (f *fakeDynamoDB) BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+	f.batchGetCalls++
+	output := &dynamodb.BatchGetItemOutput{Responses: map[string][]map[string]types.AttributeValue{}}
+	for table, request := range params.RequestItems {
+		keys := request.Keys
+		if f.batchGetCalls <= f.unprocessed && len(keys) > 0 {
+			output.UnprocessedKeys = map[string]types.KeysAndAttributes{table: {Keys: keys[len(keys)-1:]}}
+			keys = keys[:len(keys)-1]
+		}
+		for _, key := range keys {
+			if movie, ok := f.movies[keyMovieId(key)]; ok {
+				output.Responses[table] = append(output.Responses[table], f.item(movie))
+			}
+		}
+	}
+	return output, nil
+}
+
+func (f *fakeDynamoDB) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	if f.batchWriteErr != nil {
+		return nil, f.batchWriteErr
+	}
+	entry := func(item map[string]types.AttributeValue) string {
+		token, _ := item[tokenAttr].(*types.AttributeValueMemberS)
+		return token.Value + " " + keyMovieId(item)
+	}
+	for _, request := range params.RequestItems[testSearchTable] {
+		if request.PutRequest != nil {
+			f.written = append(f.written, entry(request.PutRequest.Item))
+		}
+		if request.DeleteRequest != nil {
+			f.deleted = append(f.deleted, entry(request.DeleteRequest.Key))
+		}
+	}
+	return &dynamodb.BatchWriteItemOutput{}, nil
+}
+
+// transactionCanceled is the error of a transaction cancelled for reasons,
+// one cancellation reason code per item.
+func transactionCanceled(reasons ...string) error {
+	cancelled := &types.TransactionCanceledException{Message: aws.String("Transaction cancelled")}
+	for _, reason := range reasons {
+		cancelled.CancellationReasons = append(cancelled.CancellationReasons, types.CancellationReason{Code: aws.String(reason)})
+	}
+	return cancelled
+}
+
+// renderExpression substitutes the attribute names and values into an
+// expression, so it can be compared regardless of the placeholders the
+// builder picked.
+func renderExpression(expr *string, names map[string]string, values map[string]types.AttributeValue) string {
+	var placeholders []string
+	replacements := map[string]string{}
+	for placeholder, name := range names {
+		placeholders = append(placeholders, placeholder)
+		replacements[placeholder] = name
+	}
+	for placeholder, value := range values {
+		placeholders = append(placeholders, placeholder)
+		switch value := value.(type) {
+		case *types.AttributeValueMemberS:
+			replacements[placeholder] = "'" + value.Value + "'"
+		case *types.AttributeValueMemberN:
+			replacements[placeholder] = value.Value
+		default:
+			replacements[placeholder] = "?"
+		}
+	}
+	// Longer placeholders first, so #10 is not read as #1 followed by 0.
+	slices.SortFunc(placeholders, func(a, b string) int { return cmp.Compare(len(b), len(a)) })
+
+	rendered := aws.ToString(expr)
+	for _, placeholder := range placeholders {
+		rendered = strings.ReplaceAll(rendered, placeholder, replacements[placeholder])
+	}
+	return rendered
+}
+
+func TestDynamoStoreTransactionConditions(t *testing.T) {
+	alien := "Alien"
+	version := heat.Version
+
+	tests := []struct {
+		name    string
+		write   func(s *DynamoStore) error
+		reasons []string
+		want    error
+	}{
+		{
+			name: "add with a taken title",
+			write: func(s *DynamoStore) error {
+				return s.AddMovie(context.Background(), Movie{MovieId: "new", Title: "Heat"})
+			},
+			reasons: []string{"None", "ConditionalCheckFailed"},
+			want:    ErrTitleExists,
+		},
+		{
+			name: "add failing otherwise",
+			write: func(s *DynamoStore) error {
+				return s.AddMovie(context.Background(), Movie{MovieId: "new", Title: "Heat"})
+			},
+			reasons: []string{"None", "ThrottlingError"},
+			want:    ErrUpstream,
+		},
+		{
+			name: "rename to a taken title",
+			write: func(s *DynamoStore) error {
+				_, err := s.PatchMovieById(context.Background(), heat.MovieId, MoviePatch{Title: &alien}, nil)
+				return err
+			},
+			reasons: []string{"None", "None", "ConditionalCheckFailed"},
+			want:    ErrTitleExists,
+		},
+		{
+			name: "patch at a version that moved on",
+			write: func(s *DynamoStore) error {
+				_, err := s.PatchMovieById(context.Background(), heat.MovieId, MoviePatch{Title: &alien}, &version)
+				return err
+			},
+			reasons: []string{"ConditionalCheckFailed", "None", "None"},
+			want:    ErrVersionMismatch,
+		},
+		{
+			name: "unconditional patch racing another write",
+			write: func(s *DynamoStore) error {
+				_, err := s.PatchMovieById(context.Background(), heat.MovieId, MoviePatch{Title: &alien}, nil)
+				return err
+			},
+			reasons: []string{"ConditionalCheckFailed", "None", "None"},
+			want:    errConcurrentUpdate,
+		},
+		{
+			name: "rename racing a title change",
+			write: func(s *DynamoStore) error {
+				_, err := s.PatchMovieById(context.Background(), heat.MovieId, MoviePatch{Title: &alien}, &version)
+				return err
+			},
+			reasons: []string{"None", "ConditionalCheckFailed", "None"},
+			want:    errConcurrentUpdate,
+		},
+		{
+			name: "delete at a version that moved on",
+			write: func(s *DynamoStore) error {
+				_, err := s.DeleteMovieById(context.Background(), heat.MovieId, &version)
+				return err
+			},
+			reasons: []string{"ConditionalCheckFailed", "None"},
+			want:    ErrVersionMismatch,
+		},
+		{
+			name: "delete racing a title change",
+			write: func(s *DynamoStore) error {
+				_, err := s.DeleteMovieById(context.Background(), heat.MovieId, nil)
+				return err
+			},
+			reasons: []string{"None", "ConditionalCheckFailed"},
+			want:    errConcurrentUpdate,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, fake := newFakeDynamoStore(t, heat)
+			fake.transactErr = transactionCanceled(tt.reasons...)
+
+			if err := tt.write(store); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+			if len(fake.transactInputs) != 1 || len(fake.transactInputs[0].TransactItems) != len(tt.reasons) {
+				t.Errorf("transaction items do not line up with the %d cancellation reasons", len(tt.reasons))
+			}
+		})
+	}
+}
+
+func TestDecodeCursor(t *testing.T) {
+	for name, key := range map[string]map[string]types.AttributeValue{
+		"table key": listKey(heat, false),
+		"index key": listKey(heat, true),
+	} {
+		t.Run(name, func(t *testing.T) {
+			cursor, err := encodeCursor(key)
+			if err != nil {
+				t.Fatalf("encodeCursor: %v", err)
+			}
+			decoded, err := decodeCursor(cursor, len(key) > 1)
+			if err != nil {
+				t.Fatalf("decodeCursor: %v", err)
+			}
+			if !reflect.DeepEqual(decoded, key) {
+				t.Errorf("key = %#v, want %#v", decoded, key)
+			}
+		})
+	}
+
+	encode := func(key string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(key))
+	}
+	invalid := []struct {
+		name   string
+		cursor string
+		byYear bool
+	}{
+		{"not base64", "not a cursor!", false},
+		{"not json", encode("movieId"), false},
+		{"empty key", encode(`{}`), false},
+		{"numeric movieId", encode(`{"movieId": 1}`), false},
+		{"empty movieId", encode(`{"movieId": ""}`), false},
+		{"other attribute", encode(`{"title": "Heat"}`), false},
+		{"extra attribute", encode(`{"movieId": "1", "title": "Heat"}`), false},
+		{"index key for the table", encode(`{"movieId": "1", "entityType": "movie", "releaseYear": 1995}`), false},
+		{"table key for the index", encode(`{"movieId": "1"}`), true},
+		{"other entity type", encode(`{"movieId": "1", "entityType": "title", "releaseYear": 1995}`), true},
+		{"fractional year", encode(`{"movieId": "1", "entityType": "movie", "releaseYear": 1995.5}`), true},
+		{"year out of range", encode(`{"movieId": "1", "entityType": "movie", "releaseYear": 70000}`), true},
+		{"string year", encode(`{"movieId": "1", "entityType": "movie", "releaseYear": "1995"}`), true},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeCursor(tt.cursor, tt.byYear); !errors.Is(err, ErrBadRequest) {
+				t.Errorf("err = %v, want ErrBadRequest", err)
+			}
+		})
+	}
+}
+
+func TestFilterCondition(t *testing.T) {
+	yes, no := true, false
+
+	tests := []struct {
+		name     string
+		filter   MovieFilter
+		withYear bool
+		want     string
+	}{
+		{"nothing", MovieFilter{}, true, ""},
+		{"title prefix is matched after the read", MovieFilter{TitlePrefix: "he"}, true, ""},
+		{"genre", MovieFilter{Genre: "Crime"}, true, "contains (genre, 'Crime')"},
+		{"year range", MovieFilter{Genre: "Crime", YearFrom: 1990, YearTo: 1999}, true,
+			"((contains (genre, 'Crime')) AND (releaseYear >= 1990)) AND (releaseYear <= 1999)"},
+		{"year range in the key condition", MovieFilter{YearFrom: 1990, YearTo: 1999}, false, ""},
+		{"cover and no summary", MovieFilter{HasCover: &yes, HasSummary: &no}, true,
+			"((size (coverKey) > 0) OR (size (coverUrl) > 0)) AND ((attribute_not_exists (generatedSummary)) OR (size (generatedSummary) = 0))"},
+		{"no cover", MovieFilter{HasCover: &no}, true,
+			"((attribute_not_exists (coverKey)) OR (size (coverKey) = 0)) AND ((attribute_not_exists (coverUrl)) OR (size (coverUrl) = 0))"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			condition, ok := filterCondition(tt.filter, tt.withYear)
+			if !ok {
+				if tt.want != "" {
+					t.Fatalf("no condition, want %s", tt.want)
+				}
+				return
+			}
+			expr, err := expression.NewBuilder().WithCondition(condition).Build()
+			if err != nil {
+				t.Fatalf("build: %v", err)
+			}
+			if got := renderExpression(expr.Condition(), expr.Names(), expr.Values()); got != tt.want {
+				t.Errorf("condition = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDynamoStoreGetAllMovies(t *testing.T) {
+	movie := func(movieId string) Movie {
+		return Movie{MovieId: movieId, Title: "Movie " + movieId, Version: 1}
+	}
+
+	t.Run("truncates a long page and resumes after its last movie", func(t *testing.T) {
+		store, fake := newFakeDynamoStore(t)
+		fake.scans[testTable] = []*dynamodb.ScanOutput{
+			{Items: fake.items(movie("a")), LastEvaluatedKey: movieKey("a")},
+			{Items: fake.items(movie("b"), movie("c")), LastEvaluatedKey: movieKey("c")},
+		}
+
+		movies, cursor, err := store.GetAllMovies(context.Background(), 2, "", MovieFilter{}, "", nil)
+		if err != nil {
+			t.Fatalf("GetAllMovies: %v", err)
+		}
+		var ids []string
+		for _, movie := range movies {
+			ids = append(ids, movie.MovieId)
+		}
+		if !slices.Equal(ids, []string{"a", "b"}) {
+			t.Errorf("movies = %v, want [a b]", ids)
+		}
+		if key, err := decodeCursor(cursor, false); err != nil || keyMovieId(key) != "b" {
+			t.Errorf("cursor resumes after %q (%v), want b", keyMovieId(key), err)
+		}
+		if len(fake.scanInputs) != 2 || keyMovieId(fake.scanInputs[1].ExclusiveStartKey) != "a" {
+			t.Errorf("second scan does not continue after the first")
+		}
+		if limit := aws.ToInt32(fake.scanInputs[0].Limit); limit != readPageSize {
+			t.Errorf("scan limit = %d, want %d", limit, readPageSize)
+		}
+	})
+
+	t.Run("starts from the cursor", func(t *testing.T) {
+		store, fake := newFakeDynamoStore(t)
+		cursor, err := encodeCursor(movieKey("b"))
+		if err != nil {
+			t.Fatalf("encodeCursor: %v", err)
+		}
+		if _, _, err := store.GetAllMovies(context.Background(), 2, cursor, MovieFilter{}, "", nil); err != nil {
+			t.Fatalf("GetAllMovies: %v", err)
+		}
+		if keyMovieId(fake.scanInputs[0].ExclusiveStartKey) != "b" {
+			t.Errorf("scan starts at %v, want after b", fake.scanInputs[0].ExclusiveStartKey)
+		}
+	})
+
+	t.Run("stops when the read budget is spent", func(t *testing.T) {
+		store, fake := newFakeDynamoStore(t)
+		for page := range readPageBudget + 1 {
+			lastKey := movieKey(string(rune('a' + page)))
+			fake.scans[testTable] = append(fake.scans[testTable], &dynamodb.ScanOutput{LastEvaluatedKey: lastKey})
+		}
+
+		movies, cursor, err := store.GetAllMovies(context.Background(), 2, "", MovieFilter{Genre: "Western"}, "", nil)
+		if err != nil {
+			t.Fatalf("GetAllMovies: %v", err)
+		}
+		if len(movies) != 0 || len(fake.scanInputs) != readPageBudget {
+			t.Errorf("got %d movies after %d scans, want none after %d", len(movies), len(fake.scanInputs), readPageBudget)
+		}
+		want := string(rune('a' + readPageBudget - 1))
+		if key, err := decodeCursor(cursor, false); err != nil || keyMovieId(key) != want {
+			t.Errorf("cursor resumes after %q (%v), want %q", keyMovieId(key), err, want)
+		}
+	})
+}
+
+func TestDynamoStoreGetAllMoviesByYear(t *testing.T) {
+	filter := MovieFilter{Genre: "Crime", YearFrom: 1990, YearTo: 1999, TitlePrefix: "go"}
+	goodfellas := Movie{MovieId: "2", Title: "Goodfellas", ReleaseYear: 1990, Genre: Genres{"Crime"}}
+	goldenEye := Movie{MovieId: "3", Title: "GoldenEye", ReleaseYear: 1995, Genre: Genres{"Action", "Crime"}}
+
+	t.Run("pages through the index", func(t *testing.T) {
+		store, fake := newFakeDynamoStore(t)
+		fake.queries[testTable] = []*dynamodb.QueryOutput{
+			{Items: fake.items(goodfellas, heat), LastEvaluatedKey: listKey(heat, true)},
+			{Items: fake.items(goldenEye), LastEvaluatedKey: listKey(goldenEye, true)},
+		}
+
+		movies, cursor, err := store.GetAllMovies(context.Background(), 1, "", filter, "", nil)
+		if err != nil {
+			t.Fatalf("GetAllMovies: %v", err)
+		}
+		if len(movies) != 1 || movies[0].MovieId != goodfellas.MovieId {
+			t.Errorf("movies = %+v, want only Goodfellas", movies)
+		}
+		if key, err := decodeCursor(cursor, true); err != nil || !reflect.DeepEqual(key, listKey(heat, true)) {
+			t.Errorf("cursor = %v (%v), want the index key Heat was read up to", key, err)
+		}
+
+		if len(fake.scanInputs) != 0 || len(fake.queryInputs) != 1 {
+			t.Fatalf("%d scans and %d queries, want a single query", len(fake.scanInputs), len(fake.queryInputs))
+		}
+		input := fake.queryInputs[0]
+		if aws.ToString(input.IndexName) != releaseYearIndex {
+			t.Errorf("index = %q, want %q", aws.ToString(input.IndexName), releaseYearIndex)
+		}
+		if limit := aws.ToInt32(input.Limit); limit != readPageSize {
+			t.Errorf("query limit = %d, want %d", limit, readPageSize)
+		}
+		if got, want := renderExpression(input.KeyConditionExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues),
+			"(entityType = 'movie') AND (releaseYear BETWEEN 1990 AND 1999)"; got != want {
+			t.Errorf("key condition = %s, want %s", got, want)
+		}
+		if got, want := renderExpression(input.FilterExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues),
+			"contains (genre, 'Crime')"; got != want {
+			t.Errorf("filter = %s, want %s", got, want)
+		}
+	})
+
+	t.Run("truncates a long page at the index key of its last movie", func(t *testing.T) {
+		store, fake := newFakeDynamoStore(t)
+		fake.queries[testTable] = []*dynamodb.QueryOutput{
+			{Items: fake.items(goodfellas, goldenEye), LastEvaluatedKey: listKey(goldenEye, true)},
+		}
+
+		_, cursor, err := store.GetAllMovies(context.Background(), 1, "", filter, "", nil)
+		if err != nil {
+			t.Fatalf("GetAllMovies: %v", err)
+		}
+		if key, err := decodeCursor(cursor, true); err != nil || !reflect.DeepEqual(key, listKey(goodfellas, true)) {
+			t.Errorf("cursor = %v (%v), want the index key of Goodfellas", key, err)
+		}
+	})
+
+	t.Run("starts from the cursor", func(t *testing.T) {
+		store, fake := newFakeDynamoStore(t)
+		cursor, err := encodeCursor(listKey(heat, true))
+		if err != nil {
+			t.Fatalf("encodeCursor: %v", err)
+		}
+		if _, _, err := store.GetAllMovies(context.Background(), 1, cursor, filter, "", nil); err != nil {
+			t.Fatalf("GetAllMovies: %v", err)
+		}
+		if key := fake.queryInputs[0].ExclusiveStartKey; !reflect.DeepEqual(key, listKey(heat, true)) {
+			t.Errorf("query starts at %v, want after Heat", key)
+		}
+	})
+
+	t.Run("sorts the whole catalogue latest first", func(t *testing.T) {
+		store, fake := newFakeDynamoStore(t)
+		if _, _, err := store.GetAllMovies(context.Background(), 1, "", MovieFilter{}, "-releaseYear", nil); err != nil {
+			t.Fatalf("GetAllMovies: %v", err)
+		}
+		if len(fake.scanInputs) != 0 || len(fake.queryInputs) != 1 {
+			t.Fatalf("%d scans and %d queries, want a single query", len(fake.scanInputs), len(fake.queryInputs))
+		}
+		input := fake.queryInputs[0]
+		if aws.ToBool(input.ScanIndexForward) {
+			t.Error("index read in ascending order")
+		}
+		if got := renderExpression(input.KeyConditionExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues); got != "entityType = 'movie'" {
+			t.Errorf("key condition = %s, want every movie", got)
+		}
+	})
+
+	t.Run("stops when the read budget is spent", func(t *testing.T) {
+		store, fake := newFakeDynamoStore(t)
+		for range readPageBudget + 1 {
+			fake.queries[testTable] = append(fake.queries[testTable], &dynamodb.QueryOutput{LastEvaluatedKey: listKey(heat, true)})
+		}
+
+		if _, cursor, err := store.GetAllMovies(context.Background(), 1, "", filter, "", nil); err != nil || cursor == "" {
+			t.Fatalf("GetAllMovies: cursor %q, err %v", cursor, err)
+		}
+		if len(fake.queryInputs) != readPageBudget {
+			t.Errorf("%d queries, want %d", len(fake.queryInputs), readPageBudget)
+		}
+	})
+}
+
+func TestDynamoStoreGetMoviesByIds(t *testing.T) {
+	movies := []Movie{heat, inception}
+
+	t.Run("retries unprocessed keys", func(t *testing.T) {
+		store, fake := newFakeDynamoStore(t, movies...)
+		fake.unprocessed = 1
+
+		got, err := store.GetMoviesByIds(context.Background(), []string{heat.MovieId, inception.MovieId, "missing"})
+		if err != nil {
+			t.Fatalf("GetMoviesByIds: %v", err)
+		}
+		if len(got) != 2 || fake.batchGetCalls != 2 {
+			t.Errorf("got %d movies in %d calls, want 2 in 2", len(got), fake.batchGetCalls)
+		}
+	})
+
+	t.Run("gives up on keys left unprocessed", func(t *testing.T) {
+		store, fake := newFakeDynamoStore(t, movies...)
+		fake.unprocessed = maxBatchAttempts
+
+		if _, err := store.GetMoviesByIds(context.Background(), []string{heat.MovieId}); !errors.Is(err, ErrUpstream) {
+			t.Errorf("err = %v, want ErrUpstream", err)
+		}
+		if fake.batchGetCalls != maxBatchAttempts {
+			t.Errorf("%d calls, want %d", fake.batchGetCalls, maxBatchAttempts)
+		}
+	})
+}
+
+func TestDynamoStoreUpdateMovieSummaryOfDeletedMovie(t *testing.T) {
+	store, fake := newFakeDynamoStore(t)
+	fake.updateErr = &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+
+	if err := store.UpdateMovieSummary(context.Background(), heat.MovieId, "A summary."); !errors.Is(err, ErrMovieNotFound) {
+		t.Errorf("err = %v, want ErrMovieNotFound", err)
+	}
+	input := fake.updateInputs[0]
+	if got := renderExpression(input.ConditionExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues); got != "attribute_exists (movieId)" {
+		t.Errorf("condition = %s, want attribute_exists (movieId)", got)
+	}
+}
+
+func TestDynamoStoreSearchMovies(t *testing.T) {
+	// The best match was deleted and the index not updated yet.
+	store, fake := newFakeDynamoStore(t, heat, inception)
+	type entry struct {
+		Token   string `dynamodbav:"token"`
+		MovieId string `dynamodbav:"movieId"`
+		Weight  int    `dynamodbav:"weight"`
+	}
+	fake.queries[testSearchTable] = []*dynamodb.QueryOutput{{Items: fake.items(
+		entry{"he", "deleted", 3},
+		entry{"he", heat.MovieId, 2},
+		entry{"he", inception.MovieId, 1},
+	)}}
+
+	results, err := store.SearchMovies(context.Background(), []string{"he"}, 2)
+	if err != nil {
+		t.Fatalf("SearchMovies: %v", err)
+	}
+	var ids []string
+	for _, result := range results {
+		ids = append(ids, result.MovieId)
+	}
+	if !slices.Equal(ids, []string{heat.MovieId, inception.MovieId}) {
+		t.Errorf("results = %v, want Heat and Inception", ids)
+	}
+}
+
+func TestDynamoStoreRebuildSearchIndex(t *testing.T) {
+	// Heat is renamed while the index is rebuilt, after it was scanned.
+	renamed := heat
+	renamed.Title = "Heat Wave"
+	store, fake := newFakeDynamoStore(t, renamed)
+
+	type entry struct {
+		Token   string `dynamodbav:"token"`
+		MovieId string `dynamodbav:"movieId"`
+	}
+	fake.scans[testTable] = []*dynamodb.ScanOutput{{Items: fake.items(heat)}}
+	fake.scans[testSearchTable] = []*dynamodb.ScanOutput{{Items: fake.items(
+		entry{"heat", heat.MovieId},
+		entry{"wave", heat.MovieId},
+		entry{"cold", heat.MovieId},
+		entry{"alien", "deleted"},
+	)}}
+
+	rebuild, err := store.RebuildSearchIndex(context.Background())
+	if err != nil {
+		t.Fatalf("RebuildSearchIndex: %v", err)
+	}
+	if rebuild.Indexed != 1 || rebuild.Removed != 2 {
+		t.Errorf("rebuild = %+v, want 1 indexed and 2 removed", rebuild)
+	}
+	if !slices.Contains(fake.written, "heat "+heat.MovieId) {
+		t.Errorf("heat entry not written")
+	}
+	slices.Sort(fake.deleted)
+	if want := []string{"alien deleted", "cold " + heat.MovieId}; !slices.Equal(fake.deleted, want) {
+		t.Errorf("deleted = %v, want %v", fake.deleted, want)
+	}
+}
+
+func TestDynamoStoreWriteIndex(t *testing.T) {
+	store, fake := newFakeDynamoStore(t)
+	before := Movie{MovieId: heat.MovieId, Title: "Cold Heat"}
+	after := Movie{MovieId: heat.MovieId, Title: "Heat Wave"}
+
+	if err := store.writeIndex(context.Background(), before, after); err != nil {
+		t.Fatalf("writeIndex: %v", err)
+	}
+	if !slices.Contains(fake.written, "wave "+heat.MovieId) || slices.Contains(fake.written, "heat "+heat.MovieId) {
+		t.Errorf("written = %v, want only the new words", fake.written)
+	}
+	if !slices.Contains(fake.deleted, "cold "+heat.MovieId) || slices.Contains(fake.deleted, "heat "+heat.MovieId) {
+		t.Errorf("deleted = %v, want only the words no longer in the title", fake.deleted)
+	}
+}
+
+func TestDynamoStoreIndexStream(t *testing.T) {
+	image := func(title string, genres ...string) map[string]events.DynamoDBAttributeValue {
+		return map[string]events.DynamoDBAttributeValue{
+			"movieId":     events.NewStringAttribute(heat.MovieId),
+			"title":       events.NewStringAttribute(title),
+			"releaseYear": events.NewNumberAttribute("1995"),
+			"genre":       events.NewStringSetAttribute(genres),
+			"covers":      events.NewMapAttribute(map[string]events.DynamoDBAttributeValue{"320": events.NewStringAttribute("heat-320.png")}),
+		}
+	}
+	record := func(sequenceNumber string, oldImage, newImage map[string]events.DynamoDBAttributeValue) events.DynamoDBEventRecord {
+		return events.DynamoDBEventRecord{Change: events.DynamoDBStreamRecord{SequenceNumber: sequenceNumber, OldImage: oldImage, NewImage: newImage}}
+	}
+	event := events.DynamoDBEvent{Records: []events.DynamoDBEventRecord{
+		record("1", nil, image("Cold Heat", "Crime")),
+		record("2", image("Cold Heat", "Crime"), image("Heat Wave", "Crime")),
+		record("3", image("Heat Wave", "Crime"), nil),
+	}}
+
+	t.Run("applies the records in order", func(t *testing.T) {
+		store, fake := newFakeDynamoStore(t)
+
+		response, err := store.IndexStream(context.Background(), event)
+		if err != nil || len(response.BatchItemFailures) != 0 {
+			t.Fatalf("IndexStream = %+v, %v, want no failures", response, err)
+		}
+		if !slices.Contains(fake.written, "cold "+heat.MovieId) || !slices.Contains(fake.written, "wave "+heat.MovieId) {
+			t.Errorf("written = %v, want the words of both titles", fake.written)
+		}
+		if !slices.Contains(fake.deleted, "cold "+heat.MovieId) || !slices.Contains(fake.deleted, "crime "+heat.MovieId) {
+			t.Errorf("deleted = %v, want the renamed and removed words", fake.deleted)
+		}
+	})
+
+	t.Run("reports the first failed record", func(t *testing.T) {
+		store, fake := newFakeDynamoStore(t)
+		fake.batchWriteErr = errors.New("throttled")
+
+		response, err := store.IndexStream(context.Background(), event)
+		if err != nil {
+			t.Fatalf("IndexStream: %v", err)
+		}
+		want := []events.DynamoDBBatchItemFailure{{ItemIdentifier: "1"}}
+		if !reflect.DeepEqual(response.BatchItemFailures, want) {
+			t.Errorf("failures = %+v, want %+v", response.BatchItemFailures, want)
+		}
+	})
+}
+
+func TestDynamoStorePatchCoverRemovesLegacyUrls(t *testing.T) {
+	key := "0190a2f0-0000-7000-8000-000000000001-new.png"
+	for name, patch := range map[string]MoviePatch{
+		"replace": {CoverKey: &key},
+		"remove":  {RemoveCover: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			store, fake := newFakeDynamoStore(t, heat)
+			if _, err := store.PatchMovieById(context.Background(), heat.MovieId, patch, nil); err != nil {
+				t.Fatalf("PatchMovieById: %v", err)
+			}
+			update := fake.transactInputs[0].TransactItems[0].Update
+			got := renderExpression(update.UpdateExpression, update.ExpressionAttributeNames, update.ExpressionAttributeValues)
+			if !strings.Contains(got, "coverUrl") || !strings.Contains(got, "covers") {
+				t.Errorf("update = %q, want coverUrl and covers removed", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// EntityTypeMigration reports what MigrateEntityType did.
+type EntityTypeMigration struct {
+	Scanned  int
+	Migrated int
+}
+
+// MigrateEntityType sets entityType=movie on the movies written before the
+// releaseYear index existed. The index is sparse, so until then those
+// movies are missing from year filtered lists. Movies already carrying the
+// attribute are not scanned, so it is safe to run more than once. With
+// dryRun the table is only read. It is run by cmd/migrate-entity-type.
+func (s *DynamoStore) MigrateEntityType(ctx context.Context, dryRun bool) (EntityTypeMigration, error) {
+	var migration EntityTypeMigration
+
+	filter := expression.AttributeNotExists(expression.Name(entityTypeAttr))
+	projection := expression.NamesList(expression.Name("movieId"))
+	expr, err := expression.NewBuilder().WithFilter(filter).WithProjection(projection).Build()
+	if err != nil {
+		return migration, err
+	}
+
+	paginator := dynamodb.NewScanPaginator(s.client, &dynamodb.ScanInput{
+		TableName:                 aws.String(s.tableName),
+		FilterExpression:          expr.Filter(),
+		ProjectionExpression:      expr.Projection(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return migration, upstreamError("DynamoDB", err)
+		}
+
+		var movies []Movie
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &movies); err != nil {
+			return migration, err
+		}
+		for _, movie := range movies {
+			migration.Scanned++
+			if dryRun {
+				continue
+			}
+			if err := s.migrateMovieEntityType(ctx, movie.MovieId); err != nil {
+				return migration, err
+			}
+			migration.Migrated++
+		}
+	}
+	return migration, nil
+}
+
+// migrateMovieEntityType sets the index partition key of a movie. Nothing a
+// client sees changes so the version is not bumped.
+func (s *DynamoStore) migrateMovieEntityType(ctx context.Context, movieId string) error {
+	update := expression.Set(expression.Name(entityTypeAttr), expression.Value(movieEntityType))
+	condition := expression.AttributeExists(expression.Name("movieId"))
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]types.AttributeValue{
+			"movieId": &types.AttributeValueMemberS{Value: movieId},
+		},
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		// Deleted by a concurrent write in the meantime.
+		return nil
+	}
+	if err != nil {
+		return upstreamError("DynamoDB", err)
+	}
+	return nil
+}
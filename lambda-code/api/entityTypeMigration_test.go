@@ -0,0 +1,44 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+func TestMigrateEntityType(t *testing.T) {
+	for _, dryRun := range []bool{true, false} {
+		store, fake := newFakeDynamoStore(t)
+		fake.scans[testTable] = []*dynamodb.ScanOutput{
+			{Items: fake.items(heat), LastEvaluatedKey: movieKey(heat.MovieId)},
+			{Items: fake.items(inception)},
+		}
+
+		migration, err := store.MigrateEntityType(context.Background(), dryRun)
+		if err != nil {
+			t.Fatalf("MigrateEntityType: %v", err)
+		}
+		if migration.Scanned != 2 {
+			t.Errorf("scanned %d movies, want 2", migration.Scanned)
+		}
+
+		input := fake.scanInputs[0]
+		if got := renderExpression(input.FilterExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues); got != "attribute_not_exists (entityType)" {
+			t.Errorf("filter = %s", got)
+		}
+
+		want := 2
+		if dryRun {
+			want = 0
+		}
+		if migration.Migrated != want || len(fake.updateInputs) != want {
+			t.Errorf("dryRun %v: migrated %d with %d updates, want %d", dryRun, migration.Migrated, len(fake.updateInputs), want)
+		}
+		for _, update := range fake.updateInputs {
+			if got := renderExpression(update.UpdateExpression, update.ExpressionAttributeNames, update.ExpressionAttributeValues); got != "SET entityType = 'movie'\n" {
+				t.Errorf("update = %q", got)
+			}
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Error kinds returned by the data layer and the request parsing. Callers
+// match them with errors.Is and errorResponse maps each kind to a status code.
+var (
+	ErrBadRequest         = errors.New("bad request")
+	ErrNotFound           = errors.New("not found")
+	ErrMethodNotAllowed   = errors.New("method not allowed")
+	ErrConflict           = errors.New("conflict")
+	ErrValidation         = errors.New("validation failed")
+	ErrPreconditionFailed = errors.New("precondition failed")
+	ErrPayloadTooLarge    = errors.New("payload too large")
+	ErrUpstream           = errors.New("upstream service failed")
+)
+
+var (
+	ErrMovieNotFound = newError(ErrNotFound, "No movie found")
+	// ErrTitleExists is returned when a write would give two movies the same
+	// normalized title.
+	ErrTitleExists = newError(ErrConflict, "movie with same title already exists")
+	// ErrVersionMismatch is returned when a write was conditioned on a movie
+	// version that is no longer current.
+	ErrVersionMismatch = newError(ErrPreconditionFailed, "movie has been modified since it was fetched")
+	// errConcurrentUpdate is returned when a movie changed between being read
+	// and the transaction that writes it.
+	errConcurrentUpdate = newError(ErrConflict, "movie was modified by another request, please retry")
+)
+
+// apiError is an error of one of the kinds above with a message that is safe
+// to return to clients.
+type apiError struct {
+	kind    error
+	message string
+}
+
+func newError(kind error, format string, args ...any) error {
+	return &apiError{kind: kind, message: fmt.Sprintf(format, args...)}
+}
+
+func (e *apiError) Error() string {
+	return e.message
+}
+
+func (e *apiError) Unwrap() error {
+	return e.kind
+}
+
+// badRequest is shorthand for malformed requests such as an unreadable body
+// or an invalid query parameter.
+func badRequest(format string, args ...any) error {
+	return newError(ErrBadRequest, format, args...)
+}
+
+// FieldError describes why a single request field was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError is returned when a well-formed request carries field values
+// that are not acceptable. It lists every rejected field.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, fieldError := range e.Errors {
+		messages[i] = fieldError.Message
+	}
+	return strings.Join(messages, ", ")
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrValidation
+}
+
+// Add records a rejected field.
+func (e *ValidationError) Add(field string, format string, args ...any) {
+	e.Errors = append(e.Errors, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Err returns the ValidationError if any field was rejected, otherwise nil.
+func (e *ValidationError) Err() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e
+}
+
+// MethodNotAllowedError is returned for a known path requested with a method
+// it does not serve. Allow lists the methods that are served.
+type MethodNotAllowedError struct {
+	Method string
+	Allow  []string
+}
+
+func (e *MethodNotAllowedError) Error() string {
+	return fmt.Sprintf("Method %v not allowed", e.Method)
+}
+
+func (e *MethodNotAllowedError) Is(target error) bool {
+	return target == ErrMethodNotAllowed
+}
+
+// UpstreamError wraps a failure from DynamoDB, S3 or Bedrock. The underlying
+// error is logged but not returned to clients.
+type UpstreamError struct {
+	Service string
+	Err     error
+}
+
+func upstreamError(service string, err error) error {
+	return &UpstreamError{Service: service, Err: err}
+}
+
+func (e *UpstreamError) Error() string {
+	return fmt.Sprintf("%s request failed: %v", e.Service, e.Err)
+}
+
+func (e *UpstreamError) Unwrap() error {
+	return e.Err
+}
+
+func (e *UpstreamError) Is(target error) bool {
+	return target == ErrUpstream
+}
+
+// errorStatus maps an error to the HTTP status code and message returned to
+// the client.
+func errorStatus(err error) (int, string) {
+	var upstream *UpstreamError
+
+	switch {
+	case errors.Is(err, ErrBadRequest):
+		return http.StatusBadRequest, err.Error()
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound, err.Error()
+	case errors.Is(err, ErrMethodNotAllowed):
+		return http.StatusMethodNotAllowed, err.Error()
+	case errors.Is(err, ErrConflict):
+		return http.StatusConflict, err.Error()
+	case errors.Is(err, ErrPreconditionFailed):
+		return http.StatusPreconditionFailed, err.Error()
+	case errors.Is(err, ErrPayloadTooLarge):
+		return http.StatusRequestEntityTooLarge, err.Error()
+	case errors.Is(err, ErrValidation):
+		return http.StatusUnprocessableEntity, err.Error()
+	case errors.As(err, &upstream):
+		return http.StatusBadGateway, fmt.Sprintf("%s is unavailable, please try again later", upstream.Service)
+	}
+	return http.StatusInternalServerError, "Internal server error"
+}
+
+// errorResponse renders err with the status code matching its kind, as
+// problem+json when the request accepts it and in the Response envelope
+// otherwise.
+func errorResponse(event events.APIGatewayProxyRequest, err error) events.APIGatewayProxyResponse {
+	statusCode, message := errorStatus(err)
+	var res events.APIGatewayProxyResponse
+	if acceptsProblemJson(event.Headers) {
+		res = problemResponse(statusCode, message, err, event.Path)
+	} else {
+		res = response(statusCode, false, message, nil)
+	}
+
+	var notAllowed *MethodNotAllowedError
+	if errors.As(err, &notAllowed) {
+		if res.Headers == nil {
+			res.Headers = map[string]string{}
+		}
+		res.Headers["Allow"] = strings.Join(notAllowed.Allow, ", ")
+	}
+	return res
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// GenreMigration reports what MigrateGenres did. Unknown lists the genre
+// names outside the vocabulary per movieId; they are kept as written.
+type GenreMigration struct {
+	Scanned  int
+	Migrated int
+	Unknown  map[string][]string
+}
+
+// MigrateGenres rewrites the comma separated genre strings written before
+// genres were a list into string sets, using the vocabulary spelling of each
+// genre. Movies already holding a set are left alone, so it is safe to run
+// more than once. With dryRun the table is only read. It is run by
+// cmd/migrate-genres.
+func (s *DynamoStore) MigrateGenres(ctx context.Context, dryRun bool) (GenreMigration, error) {
+	migration := GenreMigration{Unknown: map[string][]string{}}
+
+	filter := expression.AttributeType(expression.Name("genre"), expression.String)
+	projection := expression.NamesList(expression.Name("movieId"), expression.Name("genre"))
+	expr, err := expression.NewBuilder().WithFilter(filter).WithProjection(projection).Build()
+	if err != nil {
+		return migration, err
+	}
+
+	paginator := dynamodb.NewScanPaginator(s.client, &dynamodb.ScanInput{
+		TableName:                 aws.String(s.tableName),
+		FilterExpression:          expr.Filter(),
+		ProjectionExpression:      expr.Projection(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return migration, upstreamError("DynamoDB", err)
+		}
+
+		var movies []Movie
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &movies); err != nil {
+			return migration, err
+		}
+		for _, movie := range movies {
+			migration.Scanned++
+
+			genres := migratedGenres(movie.Genre)
+			for _, genre := range genres {
+				if _, ok := canonicalGenre(genre); !ok {
+					migration.Unknown[movie.MovieId] = append(migration.Unknown[movie.MovieId], genre)
+				}
+			}
+
+			if dryRun {
+				continue
+			}
+			if err := s.migrateMovieGenres(ctx, movie.MovieId, genres); err != nil {
+				return migration, err
+			}
+			migration.Migrated++
+		}
+	}
+	return migration, nil
+}
+
+// migratedGenres maps genres to their vocabulary spelling, keeping unknown
+// names trimmed but otherwise as written.
+func migratedGenres(genres Genres) Genres {
+	var migrated Genres
+	for _, genre := range genres {
+		if canonical, ok := canonicalGenre(genre); ok {
+			genre = canonical
+		} else {
+			genre = strings.Join(strings.Fields(genre), " ")
+		}
+		if !slices.Contains(migrated, genre) {
+			migrated = append(migrated, genre)
+		}
+	}
+	return migrated
+}
+
+// migrateMovieGenres stores genres as a set, provided the item still holds a
+// string, and bumps the version so clients holding the old ETag refetch.
+func (s *DynamoStore) migrateMovieGenres(ctx context.Context, movieId string, genres Genres) error {
+	update := expression.Set(expression.Name("genre"), expression.Value(genres)).
+		Add(expression.Name(versionAttr), expression.Value(1))
+	condition := expression.AttributeType(expression.Name("genre"), expression.String)
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]types.AttributeValue{
+			"movieId": &types.AttributeValueMemberS{Value: movieId},
+		},
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		// Migrated by a concurrent write in the meantime.
+		return nil
+	}
+	if err != nil {
+		return upstreamError("DynamoDB", err)
+	}
+	return nil
+}
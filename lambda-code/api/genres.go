@@ -0,0 +1,132 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// genreVocabulary lists the genres a movie can be tagged with. Input is
+// matched case-insensitively and stored with the spelling used here.
+var genreVocabulary = []string{
+	"Action",
+	"Adventure",
+	"Animation",
+	"Biography",
+	"Comedy",
+	"Crime",
+	"Documentary",
+	"Drama",
+	"Family",
+	"Fantasy",
+	"History",
+	"Horror",
+	"Musical",
+	"Mystery",
+	"Romance",
+	"Science Fiction",
+	"Sport",
+	"Superhero",
+	"Thriller",
+	"War",
+	"Western",
+}
+
+// Genres is the set of genres of a movie. It is stored as a DynamoDB string
+// set. Items and request bodies from before genres were a list hold a comma
+// separated string such as "Science Fiction, Action", which is still read.
+type Genres []string
+
+// GenreCount is the number of movies tagged with a genre.
+type GenreCount struct {
+	Genre string `json:"genre"`
+	Count int    `json:"count"`
+}
+
+// splitGenres splits a legacy comma separated genre string.
+func splitGenres(genres string) Genres {
+	var split Genres
+	for _, genre := range strings.Split(genres, ",") {
+		if genre = strings.TrimSpace(genre); genre != "" {
+			split = append(split, genre)
+		}
+	}
+	return split
+}
+
+// canonicalGenre returns the vocabulary spelling of genre.
+func canonicalGenre(genre string) (string, bool) {
+	genre = strings.Join(strings.Fields(genre), " ")
+	for _, known := range genreVocabulary {
+		if strings.EqualFold(known, genre) {
+			return known, true
+		}
+	}
+	return "", false
+}
+
+// parseGenres validates genres against the vocabulary and returns them
+// de-duplicated and sorted. Problems are added to validation under field.
+func parseGenres(genres Genres, field string, validation *ValidationError) Genres {
+	if len(genres) == 0 {
+		validation.Add(field, "'%v' field is required", field)
+		return nil
+	}
+
+	var parsed Genres
+	for _, genre := range genres {
+		canonical, ok := canonicalGenre(genre)
+		if !ok {
+			validation.Add(field, "'%v' is not a known genre, expected one of: %v", genre, strings.Join(genreVocabulary, ", "))
+			continue
+		}
+		if !slices.Contains(parsed, canonical) {
+			parsed = append(parsed, canonical)
+		}
+	}
+	slices.Sort(parsed)
+	return parsed
+}
+
+// UnmarshalJSON accepts a list of genres or a legacy comma separated string.
+func (g *Genres) UnmarshalJSON(data []byte) error {
+	var list []string
+	if err := json.Unmarshal(data, &list); err == nil {
+		*g = list
+		return nil
+	}
+
+	var legacy string
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return errors.New("genre must be a list of strings")
+	}
+	*g = splitGenres(legacy)
+	return nil
+}
+
+func (g Genres) MarshalDynamoDBAttributeValue() (types.AttributeValue, error) {
+	if len(g) == 0 {
+		return &types.AttributeValueMemberNULL{Value: true}, nil
+	}
+	return &types.AttributeValueMemberSS{Value: g}, nil
+}
+
+// UnmarshalDynamoDBAttributeValue reads a string set, and the comma
+// separated strings written before the genre migration.
+func (g *Genres) UnmarshalDynamoDBAttributeValue(av types.AttributeValue) error {
+	switch value := av.(type) {
+	case *types.AttributeValueMemberSS:
+		*g = slices.Sorted(slices.Values(value.Value))
+	case *types.AttributeValueMemberS:
+		*g = splitGenres(value.Value)
+	case *types.AttributeValueMemberNULL:
+		*g = nil
+	default:
+		return fmt.Errorf("unexpected genre attribute type %T", av)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestGenresUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		body string
+		want Genres
+	}{
+		{`["Crime", "Thriller"]`, Genres{"Crime", "Thriller"}},
+		{`"Crime, Thriller"`, Genres{"Crime", "Thriller"}},
+		{`"Crime,, "`, Genres{"Crime"}},
+		{`[]`, Genres{}},
+	}
+
+	for _, tt := range tests {
+		var genres Genres
+		if err := json.Unmarshal([]byte(tt.body), &genres); err != nil {
+			t.Errorf("Unmarshal(%s): %v", tt.body, err)
+			continue
+		}
+		if !slices.Equal(genres, tt.want) {
+			t.Errorf("Unmarshal(%s) = %q, want %q", tt.body, genres, tt.want)
+		}
+	}
+
+	var genres Genres
+	if err := json.Unmarshal([]byte(`12`), &genres); err == nil {
+		t.Error("Unmarshal(12) succeeded, want an error")
+	}
+}
+
+func TestGenresDynamoDB(t *testing.T) {
+	tests := []struct {
+		name string
+		av   types.AttributeValue
+		want Genres
+	}{
+		{"string set", &types.AttributeValueMemberSS{Value: []string{"Thriller", "Crime"}}, Genres{"Crime", "Thriller"}},
+		{"legacy string", &types.AttributeValueMemberS{Value: "Science Fiction, Action"}, Genres{"Science Fiction", "Action"}},
+		{"null", &types.AttributeValueMemberNULL{Value: true}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var movie Movie
+			item := map[string]types.AttributeValue{"genre": tt.av}
+			if err := attributevalue.UnmarshalMap(item, &movie); err != nil {
+				t.Fatalf("UnmarshalMap: %v", err)
+			}
+			if !slices.Equal(movie.Genre, tt.want) {
+				t.Errorf("genre = %q, want %q", movie.Genre, tt.want)
+			}
+		})
+	}
+
+	item, err := attributevalue.MarshalMap(Movie{Genre: Genres{"Crime"}})
+	if err != nil {
+		t.Fatalf("MarshalMap: %v", err)
+	}
+	if ss, ok := item["genre"].(*types.AttributeValueMemberSS); !ok || !slices.Equal(ss.Value, []string{"Crime"}) {
+		t.Errorf("genre marshalled as %#v, want a string set", item["genre"])
+	}
+}
+
+func TestParseGenres(t *testing.T) {
+	var validation ValidationError
+	genres := parseGenres(Genres{"thriller", " science  fiction ", "Thriller"}, "genre", &validation)
+	if !slices.Equal(genres, Genres{"Science Fiction", "Thriller"}) || validation.Err() != nil {
+		t.Errorf("parseGenres = %q, %v", genres, validation.Err())
+	}
+
+	validation = ValidationError{}
+	parseGenres(Genres{"Noir"}, "genre", &validation)
+	if validation.Err() == nil {
+		t.Error("unknown genre accepted")
+	}
+}
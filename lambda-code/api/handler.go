@@ -0,0 +1,863 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+)
+
+const (
+	defaultPageSize int32 = 25
+	maxPageSize     int   = 100
+	maxBatchGetIds  int   = 100
+)
+
+// coverUploadExpiry is how long a presigned cover upload URL is valid for.
+const coverUploadExpiry = 15 * time.Minute
+
+// Handler serves the movies API. Its dependencies are injected so the same
+// routing and validation can run against DynamoDB, S3 and Bedrock in Lambda
+// or against in-memory stand-ins in tests and local runs.
+type Handler struct {
+	config     Config
+	store      MovieStore
+	covers     CoverStore
+	summarizer Summarizer
+	router     *Router
+}
+
+func NewHandler(config Config, store MovieStore, covers CoverStore, summarizer Summarizer) *Handler {
+	h := &Handler{
+		config:     config,
+		store:      store,
+		covers:     covers,
+		summarizer: summarizer,
+	}
+	h.router = h.newRouter()
+	return h
+}
+
+func (h *Handler) HandleRequest(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	res, err := h.router.ServeEvent(ctx, event)
+	if err != nil {
+		return errorResponse(event, err), nil
+	}
+	return res, nil
+}
+
+// newRouter registers the API routes. The query parameter forms such as
+// GET /api/movies?movieId= and /api/movies/summary predate the RESTful paths
+// and are kept for existing clients.
+func (h *Handler) newRouter() *Router {
+	r := NewRouter()
+	r.Use(logRequests, cacheCoverUrls)
+
+	r.Handle("GET", "/api/movies", h.handleGetMovies)
+	r.Handle("POST", "/api/movies", h.handleAddMovie)
+	r.Handle("PUT", "/api/movies", h.handleUpdateMovie)
+	r.Handle("PATCH", "/api/movies", h.handlePatchMovie)
+	r.Handle("DELETE", "/api/movies", h.handleDeleteMovie)
+	r.Handle("GET", "/api/movies/summary", h.handleGetMovieSummary)
+	r.Handle("GET", "/api/movies/search", h.handleSearchMovies)
+	r.Handle("POST", "/api/movies/batch-get", h.handleBatchGetMovies)
+	r.Handle("GET", "/api/genres", h.handleGetGenres)
+
+	r.Handle("GET", "/api/movies/{movieId}", h.handleGetMovie)
+	r.Handle("PUT", "/api/movies/{movieId}", h.handleUpdateMovie)
+	r.Handle("PATCH", "/api/movies/{movieId}", h.handlePatchMovie)
+	r.Handle("DELETE", "/api/movies/{movieId}", h.handleDeleteMovie)
+	r.Handle("GET", "/api/movies/{movieId}/summary", h.handleGetMovieSummary)
+	r.Handle("GET", "/api/movies/{movieId}/cover", h.handleGetCover)
+	r.Handle("PUT", "/api/movies/{movieId}/cover", h.handleUpdateCover)
+	r.Handle("DELETE", "/api/movies/{movieId}/cover", h.handleDeleteCover)
+	r.Handle("POST", "/api/movies/{movieId}/cover/upload-url", h.handleCreateCoverUpload)
+	r.Handle("POST", "/api/movies/{movieId}/cover/confirm", h.handleConfirmCoverUpload)
+
+	return r
+}
+
+// cacheCoverUrls gives every request its own cache of presigned cover URLs.
+// The cache lives only as long as the invocation, well within the lifetime
+// of the URLs it holds.
+func cacheCoverUrls(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return next(withCoverUrlCache(ctx), event)
+	}
+}
+
+// logRequests attaches a logger carrying the API Gateway and Lambda request
+// IDs to the context and logs every request with its outcome.
+func logRequests(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		start := time.Now()
+
+		logger := slog.Default().With("apiRequestId", event.RequestContext.RequestID)
+		if lc, ok := lambdacontext.FromContext(ctx); ok {
+			logger = logger.With("lambdaRequestId", lc.AwsRequestID)
+		}
+		ctx = withLogger(ctx, logger)
+
+		// Bodies may carry cover images or user data, only their size is logged.
+		logger.Info("request received",
+			"method", event.HTTPMethod,
+			"path", event.Path,
+			"query", event.QueryStringParameters,
+			"headers", redactHeaders(event.Headers),
+			"bodySize", len(event.Body),
+		)
+
+		res, err := next(ctx, event)
+
+		status := res.StatusCode
+		if err != nil {
+			status, _ = errorStatus(err)
+		}
+
+		attrs := []any{"status", status, "duration", time.Since(start)}
+		switch {
+		case status >= http.StatusInternalServerError:
+			logger.Error("request failed", append(attrs, "error", err)...)
+		case err != nil:
+			logger.Info("request rejected", append(attrs, "error", err)...)
+		default:
+			logger.Info("request completed", attrs...)
+		}
+		return res, err
+	}
+}
+
+// movieIdParam returns the movieId from the path, or from the movieId query
+// parameter on the legacy routes.
+func movieIdParam(event events.APIGatewayProxyRequest) (string, error) {
+	if movieId, ok := event.PathParameters["movieId"]; ok {
+		return movieId, nil
+	}
+	if movieId, ok := event.QueryStringParameters["movieId"]; ok {
+		return movieId, nil
+	}
+	return "", badRequest("movieId query param missing")
+}
+
+func (h *Handler) handleGetMovies(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	query := event.QueryStringParameters
+
+	if movieId, ok := query["movieId"]; ok {
+		return h.getMovieById(ctx, movieId)
+	}
+
+	list, err := parseListQuery(query)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+	return h.getMovies(ctx, list)
+}
+
+func (h *Handler) handleGetGenres(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return h.getGenres(ctx)
+}
+
+func (h *Handler) handleBatchGetMovies(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	movieIds, err := readMovieIds(event)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+	return h.batchGetMovies(ctx, movieIds)
+}
+
+func (h *Handler) handleSearchMovies(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return h.searchMovies(ctx, event.QueryStringParameters["q"], event.QueryStringParameters["limit"])
+}
+
+func (h *Handler) handleGetMovie(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return h.getMovieById(ctx, event.PathParameters["movieId"])
+}
+
+func (h *Handler) handleAddMovie(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	input, err := readMovieInput(event, h.config)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+	return h.addMovie(ctx, input)
+}
+
+func (h *Handler) handleUpdateMovie(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	movieId, err := movieIdParam(event)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	input, err := readMovieInput(event, h.config)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+	return h.updateMovie(ctx, movieId, input, getHeaders(event.Headers, "If-Match"))
+}
+
+func (h *Handler) handlePatchMovie(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	movieId, err := movieIdParam(event)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	patch, err := readMoviePatch(event)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+	return h.patchMovie(ctx, movieId, patch, getHeaders(event.Headers, "If-Match"))
+}
+
+func (h *Handler) handleDeleteMovie(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	movieId, err := movieIdParam(event)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+	return h.deleteMovie(ctx, movieId, getHeaders(event.Headers, "If-Match"))
+}
+
+func (h *Handler) handleGetMovieSummary(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	movieId, err := movieIdParam(event)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+	return h.getMovieSummary(ctx, movieId)
+}
+
+func (h *Handler) handleGetCover(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return h.getCover(ctx, event.PathParameters["movieId"])
+}
+
+func (h *Handler) handleUpdateCover(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	coverImage, err := readCoverImage(event, h.config)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+	return h.updateCover(ctx, event.PathParameters["movieId"], coverImage, getHeaders(event.Headers, "If-Match"))
+}
+
+func (h *Handler) handleDeleteCover(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return h.deleteCover(ctx, event.PathParameters["movieId"], getHeaders(event.Headers, "If-Match"))
+}
+
+func (h *Handler) handleCreateCoverUpload(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	input, err := readCoverUpload(event, h.config)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+	return h.createCoverUpload(ctx, event.PathParameters["movieId"], input)
+}
+
+func (h *Handler) handleConfirmCoverUpload(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	uploadId, err := readUploadId(event)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+	return h.confirmCoverUpload(ctx, event.PathParameters["movieId"], uploadId, getHeaders(event.Headers, "If-Match"))
+}
+
+// NewHandlerFromConfig wires the handler to its AWS backed dependencies.
+// Setting MOVIE_STORE=memory swaps DynamoDB for an in-memory store for local
+// runs.
+func NewHandlerFromConfig(ctx context.Context, config Config) (*Handler, error) {
+	awsConfig, err := LoadAWSConfig(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	var store MovieStore
+	if config.MovieStore == "memory" {
+		store = NewMemoryStore()
+	} else {
+		store = NewDynamoStore(awsConfig, config)
+	}
+
+	covers := NewS3CoverStore(awsConfig, config)
+	summarizer := NewBedrockSummarizer(awsConfig, config)
+
+	return NewHandler(config, store, covers, summarizer), nil
+}
+
+// getMovies returns a page of movies. Unsorted lists page through the store
+// in its own order; sorted ones are sorted and paged here, as DynamoDB can
+// only order by the key of the table or index queried.
+func (h *Handler) getMovies(ctx context.Context, list ListQuery) (events.APIGatewayProxyResponse, error) {
+	result, nextCursor, err := h.store.GetAllMovies(ctx, list.Limit, list.Cursor, list.Filter, list.Sort, list.projection())
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	if len(result) == 0 && list.Cursor == "" && nextCursor == "" {
+		if list.Filter.isEmpty() {
+			return events.APIGatewayProxyResponse{}, newError(ErrNotFound, "No movies found")
+		}
+		return response(http.StatusOK, false, "No movies found", []Movie{}), nil
+	}
+	if result == nil {
+		// An empty page, such as one the scan budget ran out on before it
+		// found a movie.
+		result = []Movie{}
+	}
+
+	if err := h.renderCovers(ctx, result); err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	data, err := selectFields(result, list.Fields)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+	return paginatedResponse(http.StatusOK, true, "Movies fetched successfully.", data, nextCursor), nil
+}
+
+func (h *Handler) getGenres(ctx context.Context) (events.APIGatewayProxyResponse, error) {
+	counts, err := h.store.CountGenres(ctx)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	genres := make([]GenreCount, len(genreVocabulary))
+	for i, genre := range genreVocabulary {
+		genres[i] = GenreCount{Genre: genre, Count: counts[genre]}
+	}
+	return response(http.StatusOK, true, "Genres fetched successfully.", genres), nil
+}
+
+// BatchGetResult is the response of a batch get. Movies follow the order of
+// the requested ids and Missing lists the ids no movie was found for.
+type BatchGetResult struct {
+	Movies  []Movie  `json:"movies"`
+	Missing []string `json:"missing"`
+}
+
+func (h *Handler) batchGetMovies(ctx context.Context, movieIds []string) (events.APIGatewayProxyResponse, error) {
+	movies, err := h.store.GetMoviesByIds(ctx, movieIds)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+	if err := h.renderCovers(ctx, movies); err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	found := make(map[string]Movie, len(movies))
+	for _, movie := range movies {
+		found[movie.MovieId] = movie
+	}
+
+	result := BatchGetResult{Movies: []Movie{}, Missing: []string{}}
+	for _, movieId := range movieIds {
+		if movie, ok := found[movieId]; ok {
+			result.Movies = append(result.Movies, movie)
+		} else {
+			result.Missing = append(result.Missing, movieId)
+		}
+	}
+	return response(http.StatusOK, true, "Movies fetched successfully.", result), nil
+}
+
+func (h *Handler) searchMovies(ctx context.Context, query string, limit string) (events.APIGatewayProxyResponse, error) {
+	terms, err := parseSearchQuery(query)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	pageSize, err := parseLimit(limit)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	results, err := h.store.SearchMovies(ctx, terms, pageSize)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	if len(results) == 0 {
+		return response(http.StatusOK, false, "No movies found", []SearchResult{}), nil
+	}
+
+	for i := range results {
+		if err := h.renderCover(ctx, &results[i].Movie); err != nil {
+			return events.APIGatewayProxyResponse{}, err
+		}
+	}
+
+	return response(http.StatusOK, true, "Movies found.", results), nil
+}
+
+func (h *Handler) getMovieSummary(ctx context.Context, movieId string) (events.APIGatewayProxyResponse, error) {
+	if movieId == "" {
+		return events.APIGatewayProxyResponse{}, badRequest("movieId cannot be empty")
+	}
+
+	movie, err := h.store.GetMovieById(ctx, movieId)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	if movie.GeneratedSummary == "" {
+		loggerFrom(ctx).Debug("no summary stored, generating one", "movieId", movie.MovieId)
+
+		movie.GeneratedSummary, err = h.summarizer.GenerateMovieSummary(ctx, movie)
+		if err != nil {
+			return events.APIGatewayProxyResponse{}, err
+		}
+
+		// Save the summary for next time fetch for the movie
+		if err := h.store.UpdateMovieSummary(ctx, movie.MovieId, movie.GeneratedSummary); err != nil {
+			return events.APIGatewayProxyResponse{}, err
+		}
+	}
+
+	data := map[string]string{
+		"summary": movie.GeneratedSummary,
+	}
+	return response(http.StatusOK, true, "Movie summary fetched.", data), nil
+}
+
+func (h *Handler) getMovieById(ctx context.Context, movieId string) (events.APIGatewayProxyResponse, error) {
+	if movieId == "" {
+		return events.APIGatewayProxyResponse{}, badRequest("movieId cannot be empty")
+	}
+
+	movie, err := h.store.GetMovieById(ctx, movieId)
+
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	if err := h.renderCover(ctx, &movie); err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	res := response(http.StatusOK, true, "Movie fetched successfully", movie)
+	res.Headers = map[string]string{"ETag": formatETag(movie.Version)}
+	return res, nil
+}
+
+func (h *Handler) addMovie(ctx context.Context, input movieInput) (events.APIGatewayProxyResponse, error) {
+	var cover storedCover
+
+	movieId, err := generateUUID()
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, fmt.Errorf("error generating unique id: %w", err)
+	}
+
+	// check if movie image is provided
+	if input.CoverImage != nil {
+		cover, err = h.uploadCover(ctx, movieId, input.CoverImage)
+		if err != nil {
+			return events.APIGatewayProxyResponse{}, err
+		}
+	}
+
+	movie := Movie{
+		MovieId:     movieId,
+		Title:       input.Title,
+		ReleaseYear: input.ReleaseYear,
+		Genre:       input.Genre,
+		CoverKey:    cover.Key,
+		CoverKeys:   cover.VariantKeys,
+	}
+
+	if err := h.store.AddMovie(ctx, movie); err != nil {
+		// The movie was never written, don't leave its cover behind.
+		h.deleteCoverObjects(ctx, cover.objectKeys())
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	return response(http.StatusOK, true, "Movie added successfully", nil), nil
+}
+
+func (h *Handler) updateMovie(ctx context.Context, movieId string, input movieInput, ifMatch string) (events.APIGatewayProxyResponse, error) {
+	if movieId == "" {
+		return events.APIGatewayProxyResponse{}, badRequest("movieId cannot be empty")
+	}
+
+	// Check if movie exists with the provided movieId
+	movie, err := h.store.GetMovieById(ctx, movieId)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	version, err := expectedVersion(ifMatch, movie)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	var cover storedCover
+
+	// check if movie image is provided and update the existing with new
+	if input.CoverImage != nil {
+		cover, err = h.uploadCover(ctx, movie.MovieId, input.CoverImage)
+		if err != nil {
+			return events.APIGatewayProxyResponse{}, err
+		}
+	}
+
+	// PUT replaces the client editable fields; the movieId, cover and summary
+	// of the stored movie are kept unless a new cover was uploaded.
+	replacement := Movie{
+		Title:       input.Title,
+		ReleaseYear: input.ReleaseYear,
+		Genre:       input.Genre,
+		CoverKey:    cover.Key,
+		CoverKeys:   cover.VariantKeys,
+	}
+
+	writeVersion := version
+	if cover.Key != "" {
+		writeVersion = coverVersion(version, movie)
+	}
+	updated, err := h.store.UpdateMovieById(ctx, movieId, replacement, writeVersion)
+	if err != nil {
+		// The movie still points at its previous cover, only the new one is
+		// unused.
+		h.deleteCoverObjects(ctx, cover.objectKeys())
+		return events.APIGatewayProxyResponse{}, coverWriteError(err, version)
+	}
+
+	if cover.Key != "" {
+		h.deleteCoverObjects(ctx, movieCover(movie).staleKeys(cover))
+	}
+
+	res := response(http.StatusOK, true, "Movie updated successfully", nil)
+	res.Headers = map[string]string{"ETag": formatETag(updated.Version)}
+	return res, nil
+}
+
+func (h *Handler) patchMovie(ctx context.Context, movieId string, patch MoviePatch, ifMatch string) (events.APIGatewayProxyResponse, error) {
+	if movieId == "" {
+		return events.APIGatewayProxyResponse{}, badRequest("movieId cannot be empty")
+	}
+
+	// Check if movie exists with the provided movieId
+	movie, err := h.store.GetMovieById(ctx, movieId)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	version, err := expectedVersion(ifMatch, movie)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	// Removing the cover deletes the objects of the cover just read, so the
+	// write is pinned to that version like those of the cover endpoints.
+	writeVersion := version
+	if patch.RemoveCover {
+		writeVersion = coverVersion(version, movie)
+	}
+
+	updated, err := h.store.PatchMovieById(ctx, movieId, patch, writeVersion)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, coverWriteError(err, version)
+	}
+
+	if patch.RemoveCover {
+		h.deleteCoverObjects(ctx, movieCover(movie).objectKeys())
+	}
+
+	if err := h.renderCover(ctx, &updated); err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	res := response(http.StatusOK, true, "Movie updated successfully", updated)
+	res.Headers = map[string]string{"ETag": formatETag(updated.Version)}
+	return res, nil
+}
+
+func (h *Handler) deleteMovie(ctx context.Context, movieId string, ifMatch string) (events.APIGatewayProxyResponse, error) {
+	if movieId == "" {
+		return events.APIGatewayProxyResponse{}, badRequest("movieId cannot be empty")
+	}
+
+	var version *int64
+	if ifMatch != "" {
+		current, err := h.store.GetMovieById(ctx, movieId)
+		if err != nil {
+			return events.APIGatewayProxyResponse{}, err
+		}
+
+		version, err = expectedVersion(ifMatch, current)
+		if err != nil {
+			return events.APIGatewayProxyResponse{}, err
+		}
+	}
+
+	movie, err := h.store.DeleteMovieById(ctx, movieId, version)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	h.deleteCoverObjects(ctx, movieCover(movie).objectKeys())
+
+	return response(http.StatusOK, true, "Movie deleted successfully", nil), nil
+}
+
+func (h *Handler) getCover(ctx context.Context, movieId string) (events.APIGatewayProxyResponse, error) {
+	movie, err := h.store.GetMovieById(ctx, movieId)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	cover := movieCover(movie)
+	if cover.Key == "" {
+		return events.APIGatewayProxyResponse{}, newError(ErrNotFound, "Movie has no cover image")
+	}
+
+	url, err := h.covers.ObjectUrl(ctx, cover.Key)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusFound,
+		Headers:    map[string]string{"Location": url},
+	}, nil
+}
+
+func (h *Handler) updateCover(ctx context.Context, movieId string, coverImage *CoverImage, ifMatch string) (events.APIGatewayProxyResponse, error) {
+	movie, err := h.store.GetMovieById(ctx, movieId)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	version, err := expectedVersion(ifMatch, movie)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	cover, err := h.uploadCover(ctx, movie.MovieId, coverImage)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	updated, err := h.store.PatchMovieById(ctx, movieId, MoviePatch{CoverKey: &cover.Key, CoverKeys: cover.VariantKeys}, coverVersion(version, movie))
+	if err != nil {
+		// The movie still points at its previous cover, only the new one is
+		// unused.
+		h.deleteCoverObjects(ctx, cover.objectKeys())
+		return events.APIGatewayProxyResponse{}, coverWriteError(err, version)
+	}
+
+	h.deleteCoverObjects(ctx, movieCover(movie).staleKeys(cover))
+
+	if err := h.renderCover(ctx, &updated); err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	res := response(http.StatusOK, true, "Movie cover updated successfully", updated)
+	res.Headers = map[string]string{"ETag": formatETag(updated.Version)}
+	return res, nil
+}
+
+func (h *Handler) deleteCover(ctx context.Context, movieId string, ifMatch string) (events.APIGatewayProxyResponse, error) {
+	movie, err := h.store.GetMovieById(ctx, movieId)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	version, err := expectedVersion(ifMatch, movie)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	if movieCover(movie).Key == "" {
+		return events.APIGatewayProxyResponse{}, newError(ErrNotFound, "Movie has no cover image")
+	}
+
+	updated, err := h.store.PatchMovieById(ctx, movieId, MoviePatch{RemoveCover: true}, coverVersion(version, movie))
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, coverWriteError(err, version)
+	}
+
+	h.deleteCoverObjects(ctx, movieCover(movie).objectKeys())
+
+	res := response(http.StatusOK, true, "Movie cover deleted successfully", updated)
+	res.Headers = map[string]string{"ETag": formatETag(updated.Version)}
+	return res, nil
+}
+
+// CoverUpload is a presigned request for uploading a cover straight to the
+// bucket. Once it has been sent the upload is confirmed with its UploadId.
+type CoverUpload struct {
+	UploadId string `json:"uploadId"`
+	PresignedUpload
+}
+
+// coverUploadKey is the key a direct upload is staged under.
+func coverUploadKey(movieId string, uploadId string) string {
+	return movieId + "/" + uploadId
+}
+
+// createCoverUpload presigns an upload of the cover of movieId, bypassing
+// the API Gateway payload limit. The cover is only validated and attached to
+// the movie by confirmCoverUpload.
+func (h *Handler) createCoverUpload(ctx context.Context, movieId string, input coverUploadInput) (events.APIGatewayProxyResponse, error) {
+	movie, err := h.store.GetMovieById(ctx, movieId)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	uploadId, err := generateUUID()
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, fmt.Errorf("error generating upload id: %w", err)
+	}
+
+	upload, err := h.covers.PresignUpload(ctx, coverUploadKey(movie.MovieId, uploadId), input.ContentType, input.ContentLength, coverUploadExpiry)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	return response(http.StatusOK, true, "Cover upload URL created successfully", CoverUpload{UploadId: uploadId, PresignedUpload: upload}), nil
+}
+
+// confirmCoverUpload checks the object staged by a presigned upload with
+// HeadObject, validates it like a multipart cover and makes it the cover of
+// movieId. The staged object is removed once it has been stored as the cover
+// or found not to be a valid image; uploads that are never confirmed are
+// expired by the bucket lifecycle rule.
+func (h *Handler) confirmCoverUpload(ctx context.Context, movieId string, uploadId string, ifMatch string) (events.APIGatewayProxyResponse, error) {
+	uploadKey := coverUploadKey(movieId, uploadId)
+
+	object, err := h.covers.HeadUpload(ctx, uploadKey)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+	if object.Size > h.config.MaxCoverBytes {
+		h.deleteUpload(ctx, uploadKey)
+		return events.APIGatewayProxyResponse{}, newError(ErrPayloadTooLarge, "coverImage cannot be larger than %d bytes", h.config.MaxCoverBytes)
+	}
+
+	data, err := h.covers.GetUpload(ctx, uploadKey)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	coverImage, err := decodeCover(data, h.config.MaxCoverDimension)
+	if err != nil {
+		h.deleteUpload(ctx, uploadKey)
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	res, err := h.updateCover(ctx, movieId, coverImage, ifMatch)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	h.deleteUpload(ctx, uploadKey)
+	return res, nil
+}
+
+// deleteUpload removes a staged upload, only logging failures like
+// deleteCoverObjects.
+func (h *Handler) deleteUpload(ctx context.Context, uploadKey string) {
+	if err := h.covers.DeleteUpload(ctx, uploadKey); err != nil {
+		loggerFrom(ctx).Warn("unable to delete cover upload", "uploadKey", uploadKey, "error", err)
+	}
+}
+
+// uploadCover stores coverImage and its resized variants as a new cover of
+// movieId, under the keys from coverKey and variantKey. The keys are fresh
+// for every upload, so if any upload fails the objects already written are
+// removed again without touching the cover the movie is still using.
+func (h *Handler) uploadCover(ctx context.Context, movieId string, coverImage *CoverImage) (storedCover, error) {
+	variants, err := resizeCover(coverImage)
+	if err != nil {
+		return storedCover{}, fmt.Errorf("error resizing cover: %w", err)
+	}
+
+	coverId, err := generateUUID()
+	if err != nil {
+		return storedCover{}, fmt.Errorf("error generating cover id: %w", err)
+	}
+
+	cover := storedCover{Key: coverKey(movieId, coverId, coverImage.Extension)}
+	if err := h.covers.PutObject(ctx, cover.Key, coverImage.Data, coverImage.ContentType); err != nil {
+		return storedCover{}, err
+	}
+
+	for _, variant := range variants {
+		key := variantKey(movieId, coverId, variant.Width, variant.Extension)
+		if err := h.covers.PutObject(ctx, key, variant.Data, variant.ContentType); err != nil {
+			h.deleteCoverObjects(ctx, cover.objectKeys())
+			return storedCover{}, err
+		}
+		if cover.VariantKeys == nil {
+			cover.VariantKeys = CoverVariants{}
+		}
+		cover.VariantKeys[strconv.Itoa(variant.Width)] = key
+	}
+	return cover, nil
+}
+
+// renderCovers sets the cover URLs of movies from their stored object keys.
+func (h *Handler) renderCovers(ctx context.Context, movies []Movie) error {
+	for i := range movies {
+		if err := h.renderCover(ctx, &movies[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderCover sets CoverUrl and Covers from the object keys of the movie's
+// cover, in whichever form the CoverStore is configured to serve them.
+func (h *Handler) renderCover(ctx context.Context, movie *Movie) error {
+	cover := movieCover(*movie)
+	if cover.Key != "" {
+		url, err := h.covers.ObjectUrl(ctx, cover.Key)
+		if err != nil {
+			return err
+		}
+		movie.CoverUrl = url
+	}
+
+	for width, key := range cover.VariantKeys {
+		url, err := h.covers.ObjectUrl(ctx, key)
+		if err != nil {
+			return err
+		}
+		if movie.Covers == nil {
+			movie.Covers = CoverVariants{}
+		}
+		movie.Covers[width] = url
+	}
+	return nil
+}
+
+// coverVersion is the version a write replacing the cover of movie is
+// conditioned on. Without an If-Match it is the version just read, so the
+// cover deleted once the write succeeds is the one the write replaced.
+func coverVersion(version *int64, movie Movie) *int64 {
+	if version == nil {
+		return &movie.Version
+	}
+	return version
+}
+
+// coverWriteError reports a write conditioned by coverVersion on the version
+// read, rather than on the client's If-Match, failing as a concurrent update.
+func coverWriteError(err error, version *int64) error {
+	if version == nil && errors.Is(err, ErrVersionMismatch) {
+		return errConcurrentUpdate
+	}
+	return err
+}
+
+// deleteCoverObjects removes cover objects. Failures are only logged, the
+// movie has already been written and an orphaned object is harmless.
+func (h *Handler) deleteCoverObjects(ctx context.Context, objectKeys []string) {
+	if len(objectKeys) == 0 {
+		return
+	}
+
+	if err := h.covers.DeleteObjects(ctx, objectKeys); err != nil {
+		loggerFrom(ctx).Warn("unable to delete cover images", "objectKeys", objectKeys, "error", err)
+	}
+}
@@ -0,0 +1,1469 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/png"
+	"log/slog"
+	"maps"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestMain(m *testing.M) {
+	slog.SetDefault(slog.New(slog.DiscardHandler))
+	os.Exit(m.Run())
+}
+
+// fakeCoverStore is a CoverStore that keeps uploaded covers in a map keyed
+// by object key. Direct uploads are staged in uploads, keyed by upload key.
+type fakeCoverStore struct {
+	objects      map[string][]byte
+	contentTypes map[string]string
+	uploads      map[string][]byte
+	// putErr is returned by every PutObject after the first putErrAfter.
+	putErr      error
+	putErrAfter int
+	puts        int
+}
+
+func (f *fakeCoverStore) PutObject(ctx context.Context, objectKey string, body []byte, contentType string) error {
+	f.puts++
+	if f.putErr != nil && f.puts > f.putErrAfter {
+		return f.putErr
+	}
+	f.objects[objectKey] = body
+	f.contentTypes[objectKey] = contentType
+	return nil
+}
+
+func (f *fakeCoverStore) ObjectUrl(ctx context.Context, objectKey string) (string, error) {
+	return "https://covers.test/images/" + objectKey, nil
+}
+
+func (f *fakeCoverStore) DeleteObjects(ctx context.Context, objectKeys []string) error {
+	for _, objectKey := range objectKeys {
+		delete(f.objects, objectKey)
+	}
+	return nil
+}
+
+func (f *fakeCoverStore) PresignUpload(ctx context.Context, uploadKey string, contentType string, contentLength int64, expires time.Duration) (PresignedUpload, error) {
+	return PresignedUpload{
+		Url:    "https://covers.test/uploads/" + uploadKey,
+		Method: "PUT",
+		Headers: map[string]string{
+			"Content-Type":   contentType,
+			"Content-Length": strconv.FormatInt(contentLength, 10),
+		},
+		ExpiresAt: time.Now().Add(expires),
+	}, nil
+}
+
+func (f *fakeCoverStore) HeadUpload(ctx context.Context, uploadKey string) (UploadedObject, error) {
+	data, ok := f.uploads[uploadKey]
+	if !ok {
+		return UploadedObject{}, errUploadNotFound
+	}
+	return UploadedObject{Size: int64(len(data))}, nil
+}
+
+func (f *fakeCoverStore) GetUpload(ctx context.Context, uploadKey string) ([]byte, error) {
+	data, ok := f.uploads[uploadKey]
+	if !ok {
+		return nil, errUploadNotFound
+	}
+	return data, nil
+}
+
+func (f *fakeCoverStore) DeleteUpload(ctx context.Context, uploadKey string) error {
+	delete(f.uploads, uploadKey)
+	return nil
+}
+
+// fakeSummarizer is a Summarizer returning a canned summary. generating, if
+// set, runs while the summary is being generated.
+type fakeSummarizer struct {
+	summary    string
+	err        error
+	calls      int
+	generating func()
+}
+
+func (f *fakeSummarizer) GenerateMovieSummary(ctx context.Context, movie Movie) (string, error) {
+	f.calls++
+	if f.generating != nil {
+		f.generating()
+	}
+	if f.err != nil {
+		return "", f.err
+	}
+	return f.summary, nil
+}
+
+type testDeps struct {
+	store      *MemoryStore
+	covers     *fakeCoverStore
+	summarizer *fakeSummarizer
+}
+
+var (
+	heat = Movie{
+		MovieId:     "0190a2f0-0000-7000-8000-000000000001",
+		Title:       "Heat",
+		ReleaseYear: 1995,
+		Genre:       Genres{"Crime", "Thriller"},
+		CoverKey:    "0190a2f0-0000-7000-8000-000000000001.jpg",
+		Version:     1,
+	}
+	inception = Movie{
+		MovieId:          "0190a2f0-0000-7000-8000-000000000002",
+		Title:            "Inception",
+		ReleaseYear:      2010,
+		Genre:            Genres{"Science Fiction"},
+		GeneratedSummary: "A thief who steals secrets through dreams.",
+		Version:          3,
+	}
+)
+
+// legacyCoverMovie is inception with a cover written before covers were
+// stored by key.
+var legacyCoverMovie = func() Movie {
+	movie := inception
+	movie.LegacyCoverUrl = "https://movies.s3.us-east-1.amazonaws.com/images/" + inception.MovieId + ".jpg"
+	movie.LegacyCovers = CoverVariants{"150": "https://movies.s3.us-east-1.amazonaws.com/images/" + inception.MovieId + "-150w.jpg"}
+	return movie
+}()
+
+func newTestHandler(movies ...Movie) (*Handler, testDeps) {
+	deps := testDeps{
+		store:      NewMemoryStore(movies...),
+		covers:     &fakeCoverStore{objects: map[string][]byte{}, contentTypes: map[string]string{}, uploads: map[string][]byte{}},
+		summarizer: &fakeSummarizer{summary: "A generated summary."},
+	}
+	for _, movie := range movies {
+		if movie.CoverKey != "" {
+			deps.covers.objects[movie.CoverKey] = []byte("cover")
+		}
+	}
+	config := Config{
+		MaxUploadBytes:    DEFAULT_MAX_UPLOAD_BYTES,
+		MaxCoverBytes:     DEFAULT_MAX_COVER_BYTES,
+		MaxCoverDimension: DEFAULT_MAX_COVER_DIMENSION,
+	}
+	return NewHandler(config, deps.store, deps.covers, deps.summarizer), deps
+}
+
+func request(method string, query map[string]string) events.APIGatewayProxyRequest {
+	return events.APIGatewayProxyRequest{
+		HTTPMethod:            method,
+		Path:                  "/api/movies",
+		QueryStringParameters: query,
+		Headers:               map[string]string{},
+	}
+}
+
+func jsonRequest(method string, query map[string]string, body string) events.APIGatewayProxyRequest {
+	event := request(method, query)
+	event.Headers["Content-Type"] = "application/json"
+	event.Body = body
+	return event
+}
+
+// multipartRequest builds a request the way API Gateway delivers
+// multipart/form-data, base64 encoded.
+func multipartRequest(method string, query map[string]string, fields map[string]string, cover []byte) events.APIGatewayProxyRequest {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for name, value := range fields {
+		writer.WriteField(name, value)
+	}
+	if cover != nil {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", `form-data; name="coverImage"; filename="cover.png"`)
+		header.Set("Content-Type", "image/png")
+		part, _ := writer.CreatePart(header)
+		part.Write(cover)
+	}
+	writer.Close()
+
+	event := request(method, query)
+	event.Headers["Content-Type"] = writer.FormDataContentType()
+	event.Body = base64.StdEncoding.EncodeToString(body.Bytes())
+	event.IsBase64Encoded = true
+	return event
+}
+
+// testPNG returns a width by height PNG image.
+func testPNG(width, height int) []byte {
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height)))
+	return buf.Bytes()
+}
+
+func withHeader(event events.APIGatewayProxyRequest, name, value string) events.APIGatewayProxyRequest {
+	headers := map[string]string{name: value}
+	for key, existing := range event.Headers {
+		if key != name {
+			headers[key] = existing
+		}
+	}
+	event.Headers = headers
+	return event
+}
+
+func withPath(event events.APIGatewayProxyRequest, path string) events.APIGatewayProxyRequest {
+	event.Path = path
+	return event
+}
+
+type envelope struct {
+	Status     bool            `json:"status"`
+	Data       json.RawMessage `json:"data"`
+	Message    string          `json:"message"`
+	StatusCode int             `json:"statusCode"`
+	NextCursor string          `json:"nextCursor"`
+}
+
+func decodeEnvelope(t *testing.T, res events.APIGatewayProxyResponse) envelope {
+	t.Helper()
+	var env envelope
+	if err := json.Unmarshal([]byte(res.Body), &env); err != nil {
+		t.Fatalf("response body is not an envelope: %v\n%s", err, res.Body)
+	}
+	if env.StatusCode != res.StatusCode {
+		t.Errorf("envelope statusCode = %d, response status = %d", env.StatusCode, res.StatusCode)
+	}
+	return env
+}
+
+func decodeData[T any](t *testing.T, env envelope) T {
+	t.Helper()
+	var data T
+	if err := json.Unmarshal(env.Data, &data); err != nil {
+		t.Fatalf("unable to decode data: %v\n%s", err, env.Data)
+	}
+	return data
+}
+
+// isCoverKey reports whether key is the object key of a cover uploadCover
+// stored for movieId, rather than of one of its variants.
+func isCoverKey(key string, movieId string, extension string) bool {
+	return strings.HasPrefix(key, movieId+"-") && strings.HasSuffix(key, extension) && !strings.HasSuffix(key, "w"+extension)
+}
+
+func mustGet(t *testing.T, store *MemoryStore, movieId string) Movie {
+	t.Helper()
+	movie, err := store.GetMovieById(context.Background(), movieId)
+	if err != nil {
+		t.Fatalf("GetMovieById(%v): %v", movieId, err)
+	}
+	return movie
+}
+
+// handlerTest is a request sent to a handler over movies, the response it
+// must get and any further checks of the response and the dependencies.
+type handlerTest struct {
+	name       string
+	movies     []Movie
+	event      events.APIGatewayProxyRequest
+	setup      func(deps testDeps)
+	wantStatus int
+	wantOk     bool
+	check      func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps)
+}
+
+// runHandlerTests runs each test as a subtest against a fresh handler.
+func runHandlerTests(t *testing.T, tests []handlerTest) {
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, deps := newTestHandler(tt.movies...)
+			if tt.setup != nil {
+				tt.setup(deps)
+			}
+
+			res, err := handler.HandleRequest(context.Background(), tt.event)
+			if err != nil {
+				t.Fatalf("HandleRequest returned an error: %v", err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d\n%s", res.StatusCode, tt.wantStatus, res.Body)
+			}
+
+			env := decodeEnvelope(t, res)
+			if env.Status != tt.wantOk {
+				t.Errorf("envelope status = %v, want %v", env.Status, tt.wantOk)
+			}
+			if env.Message == "" {
+				t.Error("envelope message is empty")
+			}
+			if tt.check != nil {
+				tt.check(t, res, env, deps)
+			}
+		})
+	}
+}
+
+func TestHandleRequestListMovies(t *testing.T) {
+	runHandlerTests(t, []handlerTest{
+		{
+			name:       "list movies",
+			movies:     []Movie{heat, inception},
+			event:      request("GET", nil),
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				if movies := decodeData[[]Movie](t, env); len(movies) != 2 {
+					t.Errorf("got %d movies, want 2", len(movies))
+				}
+				if env.NextCursor != "" {
+					t.Errorf("nextCursor = %q on the last page", env.NextCursor)
+				}
+			},
+		},
+		{
+			name:       "list movies paginates",
+			movies:     []Movie{heat, inception},
+			event:      request("GET", map[string]string{"limit": "1"}),
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				if movies := decodeData[[]Movie](t, env); len(movies) != 1 || movies[0].MovieId != heat.MovieId {
+					t.Errorf("got %+v, want only %v", movies, heat.MovieId)
+				}
+				if env.NextCursor == "" {
+					t.Error("nextCursor missing with more movies left")
+				}
+			},
+		},
+		{
+			name:       "list movies rejects an invalid limit",
+			movies:     []Movie{heat},
+			event:      request("GET", map[string]string{"limit": "0"}),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "list movies with none stored",
+			event:      request("GET", nil),
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "get movies by year",
+			movies:     []Movie{heat, inception},
+			event:      request("GET", map[string]string{"year": "2010"}),
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				if movies := decodeData[[]Movie](t, env); len(movies) != 1 || movies[0].MovieId != inception.MovieId {
+					t.Errorf("got %+v, want only %v", movies, inception.MovieId)
+				}
+			},
+		},
+		{
+			name:       "get movies by year range",
+			movies:     []Movie{heat, inception},
+			event:      request("GET", map[string]string{"yearFrom": "1990", "yearTo": "2000"}),
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				if movies := decodeData[[]Movie](t, env); len(movies) != 1 || movies[0].MovieId != heat.MovieId {
+					t.Errorf("got %+v, want only %v", movies, heat.MovieId)
+				}
+			},
+		},
+		{
+			name: "page movies by year range in release year order",
+			movies: []Movie{heat, inception,
+				{MovieId: "0190a2f0-0000-7000-8000-000000000009", Title: "Goodfellas", ReleaseYear: 1990}},
+			event:      request("GET", map[string]string{"yearFrom": "1990", "limit": "2"}),
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				movies := decodeData[[]Movie](t, env)
+				if len(movies) != 2 || movies[0].Title != "Goodfellas" || movies[1].MovieId != heat.MovieId {
+					t.Errorf("got %+v, want Goodfellas then %v", movies, heat.MovieId)
+				}
+				if env.NextCursor == "" {
+					t.Error("nextCursor missing with more movies left")
+				}
+			},
+		},
+		{
+			name:       "list movies by genre",
+			movies:     []Movie{heat, inception},
+			event:      request("GET", map[string]string{"genre": "science fiction"}),
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				if movies := decodeData[[]Movie](t, env); len(movies) != 1 || movies[0].MovieId != inception.MovieId {
+					t.Errorf("got %+v, want only %v", movies, inception.MovieId)
+				}
+			},
+		},
+		{
+			name:       "get movies by year range and genre",
+			movies:     []Movie{heat, inception},
+			event:      request("GET", map[string]string{"yearFrom": "1990", "genre": "Thriller"}),
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				if movies := decodeData[[]Movie](t, env); len(movies) != 1 || movies[0].MovieId != heat.MovieId {
+					t.Errorf("got %+v, want only %v", movies, heat.MovieId)
+				}
+			},
+		},
+		{
+			name:       "list movies sorted by release year descending",
+			movies:     []Movie{heat, inception},
+			event:      request("GET", map[string]string{"sort": "-releaseYear"}),
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				if movies := decodeData[[]Movie](t, env); len(movies) != 2 || movies[0].MovieId != inception.MovieId {
+					t.Errorf("got %+v, want %v first", movies, inception.MovieId)
+				}
+			},
+		},
+		{
+			name:       "list movies by title prefix and cover",
+			movies:     []Movie{heat, inception},
+			event:      request("GET", map[string]string{"titlePrefix": "INC", "hasCover": "false", "hasSummary": "true"}),
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				if movies := decodeData[[]Movie](t, env); len(movies) != 1 || movies[0].MovieId != inception.MovieId {
+					t.Errorf("got %+v, want only %v", movies, inception.MovieId)
+				}
+			},
+		},
+		{
+			name:       "list movies with filters matching nothing",
+			movies:     []Movie{heat, inception},
+			event:      request("GET", map[string]string{"titlePrefix": "Alien"}),
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "list movies with sparse fields",
+			movies:     []Movie{heat, inception},
+			event:      request("GET", map[string]string{"fields": "title, movieId", "sort": "releaseYear"}),
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				movies := decodeData[[]map[string]any](t, env)
+				if len(movies) != 2 {
+					t.Fatalf("got %d movies, want 2", len(movies))
+				}
+				if len(movies[0]) != 2 || movies[0]["title"] != heat.Title || movies[0]["movieId"] != heat.MovieId {
+					t.Errorf("first movie = %v, want only the title and movieId of %v", movies[0], heat.Title)
+				}
+			},
+		},
+		{
+			name:       "list movies with an unknown field",
+			movies:     []Movie{heat},
+			event:      request("GET", map[string]string{"fields": "title,rating"}),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "list movies with an unknown parameter",
+			movies:     []Movie{heat},
+			event:      request("GET", map[string]string{"genres": "Crime"}),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "list movies with an unknown sort",
+			movies:     []Movie{heat},
+			event:      request("GET", map[string]string{"sort": "rating"}),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "list movies sorted by title",
+			movies:     []Movie{heat},
+			event:      request("GET", map[string]string{"sort": "title"}),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "list movies with an invalid hasCover",
+			movies:     []Movie{heat},
+			event:      request("GET", map[string]string{"hasCover": "maybe"}),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "list movies by an unknown genre",
+			movies:     []Movie{heat},
+			event:      request("GET", map[string]string{"genre": "Noir"}),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "get movies by inverted year range",
+			event:      request("GET", map[string]string{"yearFrom": "2000", "yearTo": "1990"}),
+			wantStatus: http.StatusBadRequest,
+		},
+	})
+}
+
+func TestHandleRequestGenreCounts(t *testing.T) {
+	runHandlerTests(t, []handlerTest{
+		{
+			name:       "genre counts",
+			movies:     []Movie{heat, inception},
+			event:      withPath(request("GET", nil), "/api/genres"),
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				counts := decodeData[[]GenreCount](t, env)
+				if len(counts) != len(genreVocabulary) {
+					t.Fatalf("got %d genres, want the whole vocabulary", len(counts))
+				}
+				for _, count := range counts {
+					want := 0
+					if count.Genre == "Crime" || count.Genre == "Thriller" || count.Genre == "Science Fiction" {
+						want = 1
+					}
+					if count.Count != want {
+						t.Errorf("%v count = %d, want %d", count.Genre, count.Count, want)
+					}
+				}
+			},
+		},
+	})
+}
+
+func TestHandleRequestGetMovie(t *testing.T) {
+	runHandlerTests(t, []handlerTest{
+		{
+			name:       "get movie by id",
+			movies:     []Movie{heat},
+			event:      request("GET", map[string]string{"movieId": heat.MovieId}),
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				if movie := decodeData[Movie](t, env); movie.Title != heat.Title {
+					t.Errorf("title = %q, want %q", movie.Title, heat.Title)
+				}
+				if etag := res.Headers["ETag"]; etag != `"1"` {
+					t.Errorf("ETag = %v, want \"1\"", etag)
+				}
+			},
+		},
+		{
+			name: "get movie renders the cover URLs from their keys",
+			movies: []Movie{func() Movie {
+				movie := heat
+				movie.CoverKeys = CoverVariants{"150": heat.MovieId + "-150w.jpg"}
+				return movie
+			}()},
+			event:      withPath(request("GET", nil), "/api/movies/"+heat.MovieId),
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				var movie map[string]json.RawMessage
+				if err := json.Unmarshal(env.Data, &movie); err != nil {
+					t.Fatal(err)
+				}
+				if got := string(movie["coverUrl"]); got != `"https://covers.test/images/`+heat.CoverKey+`"` {
+					t.Errorf("coverUrl = %v", got)
+				}
+				if got := string(movie["covers"]); got != `{"150":"https://covers.test/images/`+heat.MovieId+`-150w.jpg"}` {
+					t.Errorf("covers = %v", got)
+				}
+				if _, ok := movie["coverKey"]; ok {
+					t.Error("coverKey is exposed")
+				}
+			},
+		},
+		{
+			name:       "get unknown movie",
+			movies:     []Movie{heat},
+			event:      request("GET", map[string]string{"movieId": "missing"}),
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "get movie by path",
+			movies:     []Movie{heat},
+			event:      withPath(request("GET", nil), "/api/movies/"+heat.MovieId),
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				if movie := decodeData[Movie](t, env); movie.MovieId != heat.MovieId {
+					t.Errorf("movieId = %q, want %q", movie.MovieId, heat.MovieId)
+				}
+			},
+		},
+		{
+			name:       "get movie by path with a trailing slash",
+			movies:     []Movie{heat},
+			event:      withPath(request("GET", nil), "/api/movies/"+heat.MovieId+"/"),
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+		},
+	})
+}
+
+func TestHandleRequestAddMovie(t *testing.T) {
+	runHandlerTests(t, []handlerTest{
+		{
+			name:       "add movie from json",
+			event:      jsonRequest("POST", nil, `{"title": "Alien", "releaseYear": 1979, "genre": "Science Fiction"}`),
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				movies, _, _ := deps.store.GetAllMovies(context.Background(), 10, "", MovieFilter{}, "", nil)
+				if len(movies) != 1 || movies[0].Title != "Alien" || movies[0].MovieId == "" || movies[0].Version != 1 {
+					t.Errorf("stored %+v, want one Alien movie at version 1", movies)
+				}
+			},
+		},
+		{
+			name:       "add movie from json with read-only fields",
+			event:      jsonRequest("POST", nil, `{"title": "Alien", "releaseYear": 1979, "genre": "Science Fiction", "covers": {"150": "https://example.test/alien.jpg"}}`),
+			wantStatus: http.StatusUnprocessableEntity,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				if !strings.Contains(env.Message, "'covers' field cannot be set") {
+					t.Errorf("message = %q, want covers rejected", env.Message)
+				}
+			},
+		},
+		{
+			name: "add movie from multipart with cover",
+			event: multipartRequest("POST", nil, map[string]string{
+				"title":       "Alien",
+				"releaseYear": "1979",
+				"genre":       "Science Fiction",
+			}, testPNG(4, 6)),
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				movies, _, _ := deps.store.GetAllMovies(context.Background(), 10, "", MovieFilter{}, "", nil)
+				if len(movies) != 1 {
+					t.Fatalf("stored %d movies, want 1", len(movies))
+				}
+				key := movies[0].CoverKey
+				if !isCoverKey(key, movies[0].MovieId, ".png") {
+					t.Errorf("coverKey = %q, want a png cover of the movie", key)
+				}
+				if !bytes.Equal(deps.covers.objects[key], testPNG(4, 6)) || deps.covers.contentTypes[key] != "image/png" {
+					t.Errorf("cover %v not uploaded as image/png", key)
+				}
+			},
+		},
+		{
+			name:       "add movie with a genre list",
+			event:      jsonRequest("POST", nil, `{"title": "Alien", "releaseYear": 1979, "genre": ["horror", "Science Fiction", "Horror"]}`),
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				movies, _, _ := deps.store.GetAllMovies(context.Background(), 10, "", MovieFilter{}, "", nil)
+				if len(movies) != 1 || !slices.Equal(movies[0].Genre, Genres{"Horror", "Science Fiction"}) {
+					t.Errorf("stored %+v, want genres Horror and Science Fiction", movies)
+				}
+			},
+		},
+		{
+			name:       "add movie with an unknown genre",
+			event:      jsonRequest("POST", nil, `{"title": "Alien", "releaseYear": 1979, "genre": ["Horror", "Space Opera"]}`),
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "add movie with a taken title",
+			movies:     []Movie{heat},
+			event:      jsonRequest("POST", nil, `{"title": " heat ", "releaseYear": 2024, "genre": "Crime"}`),
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "add movie with invalid fields",
+			event:      multipartRequest("POST", nil, map[string]string{"releaseYear": "soon"}, nil),
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "add movie with unknown json field",
+			event:      jsonRequest("POST", nil, `{"title": "Alien", "releaseYear": 1979, "genre": "Horror", "rating": 5}`),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "add movie removes the cover when the write fails",
+			movies: []Movie{heat},
+			event: multipartRequest("POST", nil, map[string]string{
+				"title":       "Heat",
+				"releaseYear": "1995",
+				"genre":       "Crime",
+			}, testPNG(4, 6)),
+			wantStatus: http.StatusConflict,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				if len(deps.covers.objects) != 1 {
+					t.Errorf("covers = %v, want only the existing cover", deps.covers.objects)
+				}
+			},
+		},
+		{
+			name:  "add movie when S3 fails",
+			event: multipartRequest("POST", nil, map[string]string{"title": "Alien", "releaseYear": "1979", "genre": "Horror"}, testPNG(4, 6)),
+			setup: func(deps testDeps) {
+				deps.covers.putErr = upstreamError("S3", errors.New("connection reset"))
+			},
+			wantStatus: http.StatusBadGateway,
+		},
+		{
+			name:       "add movie with unsupported content type",
+			event:      withHeader(request("POST", nil), "Content-Type", "text/plain"),
+			wantStatus: http.StatusBadRequest,
+		},
+	})
+}
+
+func TestHandleRequestReplaceMovie(t *testing.T) {
+	runHandlerTests(t, []handlerTest{
+		{
+			name:       "replace movie keeps its id, cover and summary",
+			movies:     []Movie{heat},
+			event:      jsonRequest("PUT", map[string]string{"movieId": heat.MovieId}, `{"title": "Heat (1995)", "releaseYear": 1995, "genre": "Crime"}`),
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				movie := mustGet(t, deps.store, heat.MovieId)
+				if movie.MovieId != heat.MovieId || movie.Title != "Heat (1995)" || !slices.Equal(movie.Genre, Genres{"Crime"}) {
+					t.Errorf("stored %+v", movie)
+				}
+				if movie.CoverKey != heat.CoverKey {
+					t.Errorf("coverKey = %q, want it kept as %q", movie.CoverKey, heat.CoverKey)
+				}
+				if movie.Version != heat.Version+1 {
+					t.Errorf("version = %d, want %d", movie.Version, heat.Version+1)
+				}
+				if etag, want := res.Headers["ETag"], formatETag(heat.Version+1); etag != want {
+					t.Errorf("ETag = %v, want %v", etag, want)
+				}
+			},
+		},
+		{
+			name:   "replace movie with a new cover",
+			movies: []Movie{inception},
+			event: multipartRequest("PUT", map[string]string{"movieId": inception.MovieId}, map[string]string{
+				"title":       "Inception",
+				"releaseYear": "2010",
+				"genre":       "Thriller",
+			}, testPNG(4, 6)),
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				movie := mustGet(t, deps.store, inception.MovieId)
+				if !isCoverKey(movie.CoverKey, inception.MovieId, ".png") {
+					t.Errorf("coverKey = %q", movie.CoverKey)
+				}
+				if movie.GeneratedSummary != inception.GeneratedSummary {
+					t.Errorf("summary = %q, want it kept", movie.GeneratedSummary)
+				}
+			},
+		},
+		{
+			name:   "replace movie with a new cover deletes the previous one",
+			movies: []Movie{heat},
+			event: multipartRequest("PUT", map[string]string{"movieId": heat.MovieId}, map[string]string{
+				"title":       "Heat",
+				"releaseYear": "1995",
+				"genre":       "Crime",
+			}, testPNG(4, 6)),
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				movie := mustGet(t, deps.store, heat.MovieId)
+				if keys := slices.Collect(maps.Keys(deps.covers.objects)); !slices.Equal(keys, []string{movie.CoverKey}) {
+					t.Errorf("covers = %v, want only the new cover %q", keys, movie.CoverKey)
+				}
+			},
+		},
+		{
+			name:   "replace movie with a new cover and a taken title",
+			movies: []Movie{heat, inception},
+			event: multipartRequest("PUT", map[string]string{"movieId": inception.MovieId}, map[string]string{
+				"title":       "Heat",
+				"releaseYear": "2010",
+				"genre":       "Thriller",
+			}, testPNG(4, 6)),
+			wantStatus: http.StatusConflict,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				if keys := slices.Collect(maps.Keys(deps.covers.objects)); !slices.Equal(keys, []string{heat.CoverKey}) {
+					t.Errorf("covers = %v, want the unused upload deleted", keys)
+				}
+			},
+		},
+		{
+			name:   "replace movie with a stale If-Match",
+			movies: []Movie{inception},
+			event: withHeader(
+				jsonRequest("PUT", map[string]string{"movieId": inception.MovieId}, `{"title": "Inception", "releaseYear": 2010, "genre": "Thriller"}`),
+				"If-Match", `"1"`,
+			),
+			wantStatus: http.StatusPreconditionFailed,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				if movie := mustGet(t, deps.store, inception.MovieId); !slices.Equal(movie.Genre, inception.Genre) {
+					t.Errorf("genre = %q, want it unchanged", movie.Genre)
+				}
+			},
+		},
+		{
+			name:       "replace movie without movieId",
+			event:      jsonRequest("PUT", nil, `{"title": "Heat", "releaseYear": 1995, "genre": "Crime"}`),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "replace unknown movie",
+			event:      jsonRequest("PUT", map[string]string{"movieId": "missing"}, `{"title": "Heat", "releaseYear": 1995, "genre": "Crime"}`),
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "replace movie by path",
+			movies:     []Movie{heat},
+			event:      withPath(jsonRequest("PUT", nil, `{"title": "Heat", "releaseYear": 1995, "genre": "Drama"}`), "/api/movies/"+heat.MovieId),
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				if movie := mustGet(t, deps.store, heat.MovieId); !slices.Equal(movie.Genre, Genres{"Drama"}) {
+					t.Errorf("genre = %q, want Drama", movie.Genre)
+				}
+			},
+		},
+		{
+			name:       "replace movie with a truncated image",
+			movies:     []Movie{heat},
+			event:      withPath(multipartRequest("PUT", nil, map[string]string{"title": "Heat", "releaseYear": "1995", "genre": "Crime"}, testPNG(4, 6)[:40]), "/api/movies/"+heat.MovieId),
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+	})
+}
+
+func TestHandleRequestPatchMovie(t *testing.T) {
+	runHandlerTests(t, []handlerTest{
+		{
+			name:   "patch movie",
+			movies: []Movie{inception},
+			event: withHeader(withHeader(
+				jsonRequest("PATCH", map[string]string{"movieId": inception.MovieId}, `{"genre": "Thriller", "generatedSummary": null}`),
+				"Content-Type", "application/merge-patch+json"),
+				"If-Match", `"3"`,
+			),
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				movie := decodeData[Movie](t, env)
+				if !slices.Equal(movie.Genre, Genres{"Thriller"}) || movie.GeneratedSummary != "" || movie.Title != inception.Title {
+					t.Errorf("patched movie = %+v", movie)
+				}
+				if etag := res.Headers["ETag"]; etag != `"4"` {
+					t.Errorf("ETag = %v, want \"4\"", etag)
+				}
+			},
+		},
+		{
+			name:       "patch movie removes its cover",
+			movies:     []Movie{heat},
+			event:      jsonRequest("PATCH", map[string]string{"movieId": heat.MovieId}, `{"coverUrl": null}`),
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				if movie := mustGet(t, deps.store, heat.MovieId); movie.CoverKey != "" {
+					t.Errorf("coverKey = %q, want it removed", movie.CoverKey)
+				}
+				if len(deps.covers.objects) != 0 {
+					t.Errorf("covers = %v, want the cover deleted", deps.covers.objects)
+				}
+			},
+		},
+		{
+			name:       "patch movie removes its cover with covers",
+			movies:     []Movie{heat},
+			event:      jsonRequest("PATCH", map[string]string{"movieId": heat.MovieId}, `{"covers": null}`),
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				if movie := mustGet(t, deps.store, heat.MovieId); movie.CoverKey != "" {
+					t.Errorf("coverKey = %q, want it removed", movie.CoverKey)
+				}
+				if len(deps.covers.objects) != 0 {
+					t.Errorf("covers = %v, want the cover deleted", deps.covers.objects)
+				}
+			},
+		},
+		{
+			name:       "patch movie sets covers",
+			movies:     []Movie{heat},
+			event:      jsonRequest("PATCH", map[string]string{"movieId": heat.MovieId}, `{"covers": {"320": "https://example.com/heat.png"}}`),
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "patch movie with an invalid field",
+			movies:     []Movie{heat},
+			event:      jsonRequest("PATCH", map[string]string{"movieId": heat.MovieId}, `{"releaseYear": "soon"}`),
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "patch movie by path",
+			movies:     []Movie{heat},
+			event:      withPath(jsonRequest("PATCH", nil, `{"releaseYear": 1996}`), "/api/movies/"+heat.MovieId),
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+		},
+	})
+}
+
+// coverReplacingStore replaces the cover of a movie right after it is first
+// read, as a request racing the one under test would.
+type coverReplacingStore struct {
+	*MemoryStore
+	coverKey string
+	replaced bool
+}
+
+func (s *coverReplacingStore) GetMovieById(ctx context.Context, movieId string) (Movie, error) {
+	movie, err := s.MemoryStore.GetMovieById(ctx, movieId)
+	if err == nil && !s.replaced {
+		s.replaced = true
+		if _, err := s.MemoryStore.PatchMovieById(ctx, movieId, MoviePatch{CoverKey: &s.coverKey}, nil); err != nil {
+			return Movie{}, err
+		}
+	}
+	return movie, err
+}
+
+func TestHandleRequestPatchRemovesCoverReplacedConcurrently(t *testing.T) {
+	_, deps := newTestHandler(heat)
+	store := &coverReplacingStore{MemoryStore: deps.store, coverKey: heat.MovieId + "-new.png"}
+	deps.covers.objects[store.coverKey] = []byte("new cover")
+	handler := NewHandler(Config{}, store, deps.covers, deps.summarizer)
+
+	event := jsonRequest("PATCH", map[string]string{"movieId": heat.MovieId}, `{"coverUrl": null}`)
+	res, err := handler.HandleRequest(context.Background(), event)
+	if err != nil {
+		t.Fatalf("HandleRequest returned an error: %v", err)
+	}
+	if res.StatusCode != http.StatusConflict {
+		t.Errorf("status = %d, want %d", res.StatusCode, http.StatusConflict)
+	}
+	if movie := mustGet(t, deps.store, heat.MovieId); movie.CoverKey != store.coverKey {
+		t.Errorf("coverKey = %q, want the concurrent cover %q kept", movie.CoverKey, store.coverKey)
+	}
+	if _, ok := deps.covers.objects[heat.CoverKey]; !ok {
+		t.Error("the cover read was deleted although the write failed")
+	}
+}
+
+func TestHandleRequestDeleteMovie(t *testing.T) {
+	runHandlerTests(t, []handlerTest{
+		{
+			name:       "delete movie",
+			movies:     []Movie{heat, inception},
+			event:      request("DELETE", map[string]string{"movieId": heat.MovieId}),
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				if _, err := deps.store.GetMovieById(context.Background(), heat.MovieId); !errors.Is(err, ErrNotFound) {
+					t.Errorf("GetMovieById after delete: %v, want not found", err)
+				}
+				if len(deps.covers.objects) != 0 {
+					t.Errorf("covers = %v, want the cover deleted", deps.covers.objects)
+				}
+			},
+		},
+		{
+			name:       "delete unknown movie",
+			event:      request("DELETE", map[string]string{"movieId": "missing"}),
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "delete movie with a stale If-Match",
+			movies:     []Movie{heat},
+			event:      withHeader(request("DELETE", map[string]string{"movieId": heat.MovieId}), "If-Match", `"7"`),
+			wantStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:       "delete movie by path",
+			movies:     []Movie{heat},
+			event:      withPath(request("DELETE", nil), "/api/movies/"+heat.MovieId),
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				if _, err := deps.store.GetMovieById(context.Background(), heat.MovieId); !errors.Is(err, ErrNotFound) {
+					t.Errorf("GetMovieById after delete: %v, want not found", err)
+				}
+			},
+		},
+	})
+}
+
+func TestHandleRequestSummary(t *testing.T) {
+	runHandlerTests(t, []handlerTest{
+		{
+			name:       "get stored summary",
+			movies:     []Movie{inception},
+			event:      withPath(request("GET", map[string]string{"movieId": inception.MovieId}), "/api/movies/summary"),
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				if data := decodeData[map[string]string](t, env); data["summary"] != inception.GeneratedSummary {
+					t.Errorf("summary = %q", data["summary"])
+				}
+				if deps.summarizer.calls != 0 {
+					t.Errorf("summarizer called %d times for a stored summary", deps.summarizer.calls)
+				}
+			},
+		},
+		{
+			name:       "generate missing summary",
+			movies:     []Movie{heat},
+			event:      withPath(request("GET", map[string]string{"movieId": heat.MovieId}), "/api/movies/summary"),
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				if movie := mustGet(t, deps.store, heat.MovieId); movie.GeneratedSummary != "A generated summary." {
+					t.Errorf("stored summary = %q", movie.GeneratedSummary)
+				}
+			},
+		},
+		{
+			name:   "summary when Bedrock fails",
+			movies: []Movie{heat},
+			event:  withPath(request("GET", map[string]string{"movieId": heat.MovieId}), "/api/movies/summary"),
+			setup: func(deps testDeps) {
+				deps.summarizer.err = upstreamError("Bedrock", errors.New("throttled"))
+			},
+			wantStatus: http.StatusBadGateway,
+		},
+		{
+			name:   "summary of a movie deleted while it is generated",
+			movies: []Movie{heat},
+			event:  withPath(request("GET", map[string]string{"movieId": heat.MovieId}), "/api/movies/summary"),
+			setup: func(deps testDeps) {
+				deps.summarizer.generating = func() {
+					if _, err := deps.store.DeleteMovieById(context.Background(), heat.MovieId, nil); err != nil {
+						panic(err)
+					}
+				}
+			},
+			wantStatus: http.StatusNotFound,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				if _, err := deps.store.GetMovieById(context.Background(), heat.MovieId); !errors.Is(err, ErrMovieNotFound) {
+					t.Errorf("deleted movie recreated by its summary, err = %v", err)
+				}
+			},
+		},
+		{
+			name:       "get summary by path",
+			movies:     []Movie{inception},
+			event:      withPath(request("GET", nil), "/api/movies/"+inception.MovieId+"/summary"),
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+		},
+	})
+}
+
+func TestHandleRequestCover(t *testing.T) {
+	runHandlerTests(t, []handlerTest{
+		{
+			name:   "replace cover",
+			movies: []Movie{heat},
+			event: withPath(
+				multipartRequest("PUT", nil, nil, testPNG(4, 6)),
+				"/api/movies/"+heat.MovieId+"/cover",
+			),
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				newKey := mustGet(t, deps.store, heat.MovieId).CoverKey
+				if !isCoverKey(newKey, heat.MovieId, ".png") {
+					t.Errorf("coverKey = %q", newKey)
+				}
+				if movie := decodeData[Movie](t, env); movie.CoverUrl != "https://covers.test/images/"+newKey {
+					t.Errorf("coverUrl = %q", movie.CoverUrl)
+				}
+				if _, ok := deps.covers.objects[newKey]; !ok || len(deps.covers.objects) != 1 {
+					t.Errorf("covers = %v, want only the new cover", slices.Collect(maps.Keys(deps.covers.objects)))
+				}
+				if etag := res.Headers["ETag"]; etag != `"2"` {
+					t.Errorf("ETag = %v, want \"2\"", etag)
+				}
+			},
+		},
+		{
+			name:   "replace cover with variants",
+			movies: []Movie{heat},
+			event: withPath(
+				multipartRequest("PUT", nil, nil, testPNG(400, 600)),
+				"/api/movies/"+heat.MovieId+"/cover",
+			),
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				movie := decodeData[Movie](t, env)
+				// Variants are stored next to the original, {key}-{width}w.png.
+				base := strings.TrimSuffix(mustGet(t, deps.store, heat.MovieId).CoverKey, ".png")
+				want := CoverVariants{
+					"150": "https://covers.test/images/" + base + "-150w.png",
+					"300": "https://covers.test/images/" + base + "-300w.png",
+				}
+				if !maps.Equal(movie.Covers, want) {
+					t.Errorf("covers = %v, want %v", movie.Covers, want)
+				}
+
+				thumbnail, err := png.DecodeConfig(bytes.NewReader(deps.covers.objects[base+"-150w.png"]))
+				if err != nil || thumbnail.Width != 150 || thumbnail.Height != 225 {
+					t.Errorf("150w variant is %dx%d (%v), want 150x225", thumbnail.Width, thumbnail.Height, err)
+				}
+				if _, ok := deps.covers.objects[heat.MovieId+".jpg"]; ok {
+					t.Error("previous jpg cover not deleted")
+				}
+			},
+		},
+		{
+			name:   "replace cover when storing a variant fails",
+			movies: []Movie{heat},
+			event: withPath(
+				multipartRequest("PUT", nil, nil, testPNG(400, 600)),
+				"/api/movies/"+heat.MovieId+"/cover",
+			),
+			setup: func(deps testDeps) {
+				deps.covers.putErr = upstreamError("S3", errors.New("connection reset"))
+				deps.covers.putErrAfter = 1
+			},
+			wantStatus: http.StatusBadGateway,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				if keys := slices.Collect(maps.Keys(deps.covers.objects)); !slices.Equal(keys, []string{heat.CoverKey}) {
+					t.Errorf("covers = %v, want only the current cover kept", keys)
+				}
+				if movie := mustGet(t, deps.store, heat.MovieId); movie.CoverKey != heat.CoverKey {
+					t.Errorf("coverKey = %q, want it unchanged", movie.CoverKey)
+				}
+			},
+		},
+		{
+			name:       "get movie with a cover stored before keys",
+			movies:     []Movie{legacyCoverMovie},
+			event:      request("GET", map[string]string{"movieId": inception.MovieId}),
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				movie := decodeData[Movie](t, env)
+				if movie.CoverUrl != "https://covers.test/images/"+inception.MovieId+".jpg" {
+					t.Errorf("coverUrl = %q, want it rendered from the legacy URL", movie.CoverUrl)
+				}
+				if want := (CoverVariants{"150": "https://covers.test/images/" + inception.MovieId + "-150w.jpg"}); !maps.Equal(movie.Covers, want) {
+					t.Errorf("covers = %v, want %v", movie.Covers, want)
+				}
+			},
+		},
+		{
+			name:       "replace a cover stored before keys",
+			movies:     []Movie{legacyCoverMovie},
+			event:      withPath(multipartRequest("PUT", nil, nil, testPNG(4, 6)), "/api/movies/"+inception.MovieId+"/cover"),
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+			setup: func(deps testDeps) {
+				deps.covers.objects[inception.MovieId+".jpg"] = []byte("cover")
+				deps.covers.objects[inception.MovieId+"-150w.jpg"] = []byte("thumbnail")
+			},
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				movie := mustGet(t, deps.store, inception.MovieId)
+				if movie.LegacyCoverUrl != "" || movie.LegacyCovers != nil {
+					t.Errorf("stored %+v, want the legacy URLs removed", movie)
+				}
+				if keys := slices.Collect(maps.Keys(deps.covers.objects)); !slices.Equal(keys, []string{movie.CoverKey}) {
+					t.Errorf("covers = %v, want only the new cover", keys)
+				}
+			},
+		},
+		{
+			name: "delete cover removes its variants",
+			movies: []Movie{func() Movie {
+				movie := inception
+				movie.CoverKey = inception.MovieId + ".jpg"
+				movie.CoverKeys = CoverVariants{"150": inception.MovieId + "-150w.jpg"}
+				return movie
+			}()},
+			event:      withPath(request("DELETE", nil), "/api/movies/"+inception.MovieId+"/cover"),
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+			setup: func(deps testDeps) {
+				deps.covers.objects[inception.MovieId+"-150w.jpg"] = []byte("thumbnail")
+			},
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				if len(deps.covers.objects) != 0 {
+					t.Errorf("covers = %v, want every object deleted", slices.Collect(maps.Keys(deps.covers.objects)))
+				}
+				if movie := mustGet(t, deps.store, inception.MovieId); movie.CoverKey != "" || movie.CoverKeys != nil {
+					t.Errorf("stored %+v, want the cover removed", movie)
+				}
+			},
+		},
+		{
+			name:   "replace cover with a file that is not an image",
+			movies: []Movie{heat},
+			event: withPath(
+				multipartRequest("PUT", nil, nil, []byte("%PDF-1.7")),
+				"/api/movies/"+heat.MovieId+"/cover",
+			),
+			wantStatus: http.StatusUnprocessableEntity,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				if movie := mustGet(t, deps.store, heat.MovieId); movie.CoverKey != heat.CoverKey {
+					t.Errorf("coverKey = %q, want it unchanged", movie.CoverKey)
+				}
+			},
+		},
+		{
+			name:       "replace cover without a file",
+			movies:     []Movie{heat},
+			event:      withPath(multipartRequest("PUT", nil, map[string]string{"title": "Heat"}, nil), "/api/movies/"+heat.MovieId+"/cover"),
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "delete cover",
+			movies:     []Movie{heat},
+			event:      withPath(request("DELETE", nil), "/api/movies/"+heat.MovieId+"/cover"),
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				if movie := mustGet(t, deps.store, heat.MovieId); movie.CoverKey != "" {
+					t.Errorf("coverKey = %q, want it removed", movie.CoverKey)
+				}
+				if len(deps.covers.objects) != 0 {
+					t.Errorf("covers = %v, want the cover deleted", deps.covers.objects)
+				}
+			},
+		},
+		{
+			name:       "delete missing cover",
+			movies:     []Movie{inception},
+			event:      withPath(request("DELETE", nil), "/api/movies/"+inception.MovieId+"/cover"),
+			wantStatus: http.StatusNotFound,
+		},
+	})
+}
+
+func TestHandleRequestCoverUpload(t *testing.T) {
+	runHandlerTests(t, []handlerTest{
+		{
+			name:       "create cover upload URL",
+			movies:     []Movie{heat},
+			event:      withPath(jsonRequest("POST", nil, `{"contentType": "image/png", "contentLength": 2048}`), "/api/movies/"+heat.MovieId+"/cover/upload-url"),
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				upload := decodeData[CoverUpload](t, env)
+				if upload.Url != "https://covers.test/uploads/"+heat.MovieId+"/"+upload.UploadId || upload.Method != "PUT" {
+					t.Errorf("upload = %+v", upload)
+				}
+				if upload.Headers["Content-Type"] != "image/png" || upload.Headers["Content-Length"] != "2048" {
+					t.Errorf("headers = %v, want the content type and length signed", upload.Headers)
+				}
+			},
+		},
+		{
+			name:       "create cover upload URL for an unsupported type",
+			movies:     []Movie{heat},
+			event:      withPath(jsonRequest("POST", nil, `{"contentType": "application/pdf", "contentLength": 2048}`), "/api/movies/"+heat.MovieId+"/cover/upload-url"),
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "create cover upload URL for a cover that is too large",
+			movies:     []Movie{heat},
+			event:      withPath(jsonRequest("POST", nil, `{"contentType": "image/jpeg", "contentLength": `+strconv.FormatInt(DEFAULT_MAX_COVER_BYTES+1, 10)+`}`), "/api/movies/"+heat.MovieId+"/cover/upload-url"),
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:       "create cover upload URL for a missing movie",
+			event:      withPath(jsonRequest("POST", nil, `{"contentType": "image/png", "contentLength": 2048}`), "/api/movies/"+heat.MovieId+"/cover/upload-url"),
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:   "confirm cover upload",
+			movies: []Movie{heat},
+			event:  withPath(jsonRequest("POST", nil, `{"uploadId": "0190a2f0-0000-7000-8000-0000000000aa"}`), "/api/movies/"+heat.MovieId+"/cover/confirm"),
+			setup: func(deps testDeps) {
+				deps.covers.uploads[heat.MovieId+"/0190a2f0-0000-7000-8000-0000000000aa"] = testPNG(400, 600)
+			},
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				movie := mustGet(t, deps.store, heat.MovieId)
+				if !isCoverKey(movie.CoverKey, heat.MovieId, ".png") || len(movie.CoverKeys) != 2 {
+					t.Errorf("stored %+v, want the uploaded cover and its variants", movie)
+				}
+				if !bytes.Equal(deps.covers.objects[movie.CoverKey], testPNG(400, 600)) {
+					t.Error("uploaded cover not stored")
+				}
+				if len(deps.covers.uploads) != 0 {
+					t.Errorf("uploads = %v, want the staged upload deleted", slices.Collect(maps.Keys(deps.covers.uploads)))
+				}
+			},
+		},
+		{
+			name:       "confirm cover upload before uploading",
+			movies:     []Movie{heat},
+			event:      withPath(jsonRequest("POST", nil, `{"uploadId": "0190a2f0-0000-7000-8000-0000000000aa"}`), "/api/movies/"+heat.MovieId+"/cover/confirm"),
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:   "confirm cover upload of a file that is not an image",
+			movies: []Movie{heat},
+			event:  withPath(jsonRequest("POST", nil, `{"uploadId": "0190a2f0-0000-7000-8000-0000000000aa"}`), "/api/movies/"+heat.MovieId+"/cover/confirm"),
+			setup: func(deps testDeps) {
+				deps.covers.uploads[heat.MovieId+"/0190a2f0-0000-7000-8000-0000000000aa"] = []byte("%PDF-1.7")
+			},
+			wantStatus: http.StatusUnprocessableEntity,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				if movie := mustGet(t, deps.store, heat.MovieId); movie.CoverKey != heat.CoverKey {
+					t.Errorf("coverKey = %q, want it unchanged", movie.CoverKey)
+				}
+				if len(deps.covers.uploads) != 0 {
+					t.Errorf("uploads = %v, want the invalid upload deleted", slices.Collect(maps.Keys(deps.covers.uploads)))
+				}
+			},
+		},
+		{
+			name:       "confirm cover upload with an invalid upload id",
+			movies:     []Movie{heat},
+			event:      withPath(jsonRequest("POST", nil, `{"uploadId": "../other"}`), "/api/movies/"+heat.MovieId+"/cover/confirm"),
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+	})
+}
+
+func TestHandleRequestSearch(t *testing.T) {
+	runHandlerTests(t, []handlerTest{
+		{
+			name:       "search movies",
+			movies:     []Movie{heat, inception},
+			event:      withPath(request("GET", map[string]string{"q": "thrill crime"}), "/api/movies/search"),
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				results := decodeData[[]SearchResult](t, env)
+				if len(results) != 1 || results[0].MovieId != heat.MovieId || results[0].Score != genreWeight+2*genreWeight {
+					t.Errorf("results = %+v, want only %v", results, heat.MovieId)
+				}
+			},
+		},
+		{
+			name:       "search movies without matches",
+			movies:     []Movie{heat, inception},
+			event:      withPath(request("GET", map[string]string{"q": "western"}), "/api/movies/search"),
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "search movies without a query",
+			event:      withPath(request("GET", nil), "/api/movies/search"),
+			wantStatus: http.StatusBadRequest,
+		},
+	})
+}
+
+func TestHandleRequestBatchGet(t *testing.T) {
+	runHandlerTests(t, []handlerTest{
+		{
+			name:       "batch get movies",
+			movies:     []Movie{heat, inception},
+			event:      withPath(jsonRequest("POST", nil, `{"movieIds": ["`+inception.MovieId+`", "missing", "`+heat.MovieId+`", "`+inception.MovieId+`"]}`), "/api/movies/batch-get"),
+			wantStatus: http.StatusOK,
+			wantOk:     true,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				result := decodeData[BatchGetResult](t, env)
+				if len(result.Movies) != 2 || result.Movies[0].MovieId != inception.MovieId || result.Movies[1].MovieId != heat.MovieId {
+					t.Errorf("movies = %+v, want inception then heat", result.Movies)
+				}
+				if !slices.Equal(result.Missing, []string{"missing"}) {
+					t.Errorf("missing = %v, want [missing]", result.Missing)
+				}
+			},
+		},
+		{
+			name:       "batch get without ids",
+			event:      withPath(jsonRequest("POST", nil, `{"movieIds": []}`), "/api/movies/batch-get"),
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "batch get with too many ids",
+			event:      withPath(jsonRequest("POST", nil, `{"movieIds": [`+strings.Repeat(`"id",`, 100)+`"last"]}`), "/api/movies/batch-get"),
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+	})
+}
+
+func TestHandleRequestRouting(t *testing.T) {
+	runHandlerTests(t, []handlerTest{
+		{
+			name:       "unsupported method on a movie",
+			movies:     []Movie{heat},
+			event:      withPath(request("POST", nil), "/api/movies/"+heat.MovieId),
+			wantStatus: http.StatusMethodNotAllowed,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				if allow := res.Headers["Allow"]; allow != "GET, PUT, PATCH, DELETE" {
+					t.Errorf("Allow = %q", allow)
+				}
+			},
+		},
+		{
+			name:       "unsupported method",
+			event:      request("TRACE", nil),
+			wantStatus: http.StatusMethodNotAllowed,
+			check: func(t *testing.T, res events.APIGatewayProxyResponse, env envelope, deps testDeps) {
+				if allow := res.Headers["Allow"]; allow != "GET, POST, PUT, PATCH, DELETE" {
+					t.Errorf("Allow = %q", allow)
+				}
+			},
+		},
+		{
+			name:       "unknown path",
+			event:      withPath(request("GET", nil), "/api/actors"),
+			wantStatus: http.StatusNotFound,
+		},
+	})
+}
+
+func TestHandleRequestProblemJson(t *testing.T) {
+	handler, _ := newTestHandler()
+
+	event := withHeader(multipartRequest("POST", nil, map[string]string{"releaseYear": "soon"}, nil), "Accept", "application/problem+json")
+
+	res, err := handler.HandleRequest(context.Background(), event)
+	if err != nil {
+		t.Fatalf("HandleRequest returned an error: %v", err)
+	}
+	if contentType := res.Headers["Content-Type"]; contentType != problemContentType {
+		t.Fatalf("Content-Type = %q, want %q", contentType, problemContentType)
+	}
+
+	var problem Problem
+	if err := json.Unmarshal([]byte(res.Body), &problem); err != nil {
+		t.Fatalf("body is not a problem: %v", err)
+	}
+	if problem.Status != http.StatusUnprocessableEntity || problem.Type != "urn:movies-api:problem:validation-error" {
+		t.Errorf("problem = %+v", problem)
+	}
+	if len(problem.Errors) != 3 {
+		t.Errorf("errors = %+v, want title, releaseYear and genre rejected", problem.Errors)
+	}
+}
+
+func TestHandleRequestCoverRedirect(t *testing.T) {
+	handler, _ := newTestHandler(heat)
+
+	res, err := handler.HandleRequest(context.Background(), withPath(request("GET", nil), "/api/movies/"+heat.MovieId+"/cover"))
+	if err != nil {
+		t.Fatalf("HandleRequest returned an error: %v", err)
+	}
+	want := "https://covers.test/images/" + heat.CoverKey
+	if res.StatusCode != http.StatusFound || res.Headers["Location"] != want {
+		t.Errorf("got %d to %q, want a redirect to %q", res.StatusCode, res.Headers["Location"], want)
+	}
+}
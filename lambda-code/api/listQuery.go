@@ -0,0 +1,253 @@
+package api
+
+import (
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// listParams are the query parameters GET /api/movies understands. Any other
+// parameter is rejected so that a misspelt filter does not silently return
+// the unfiltered catalogue.
+var listParams = []string{
+	"movieId", "limit", "cursor", "sort", "fields",
+	"genre", "year", "yearFrom", "yearTo", "titlePrefix", "hasCover", "hasSummary",
+}
+
+// Sort orders accepted by the sort parameter. A leading "-" reverses the
+// order. Only orders the stores can page through an index in are offered,
+// releaseYear is read from the releaseYear index.
+var listSorts = []string{"releaseYear"}
+
+// movieFields are the fields a list can be narrowed to with the fields
+// parameter. The JSON names match the DynamoDB attribute names, apart from
+// the cover URLs, see fieldAttribute.
+var movieFields = []string{"movieId", "title", "releaseYear", "genre", "coverUrl", "covers", "generatedSummary"}
+
+const maxTitlePrefixLength = 100
+
+// MovieFilter narrows the movies returned by a list. Zero values leave a
+// field unfiltered.
+type MovieFilter struct {
+	// Genre is a genre from the vocabulary the movie must be tagged with.
+	Genre string
+	// YearFrom and YearTo bound the release year, inclusive.
+	YearFrom uint16
+	YearTo   uint16
+	// TitlePrefix is matched against the start of the title, compared with
+	// normalizeTitle.
+	TitlePrefix string
+	HasCover    *bool
+	HasSummary  *bool
+}
+
+// ListQuery is a parsed GET /api/movies request.
+type ListQuery struct {
+	Filter MovieFilter
+	// Sort is one of listSorts, optionally prefixed with "-", or empty for
+	// the store's own order.
+	Sort string
+	// Fields are the movie fields to return, all of them when empty.
+	Fields []string
+	Limit  int32
+	Cursor string
+}
+
+// hasYear reports whether the filter bounds the release year, which the
+// stores answer from the releaseYear index, in year order.
+func (f MovieFilter) hasYear() bool {
+	return f.YearFrom != 0 || f.YearTo != 0
+}
+
+func (f MovieFilter) isEmpty() bool {
+	return f == MovieFilter{}
+}
+
+// matches reports whether movie passes every filter.
+func (f MovieFilter) matches(movie Movie) bool {
+	if f.Genre != "" && !slices.Contains(movie.Genre, f.Genre) {
+		return false
+	}
+	if f.YearFrom != 0 && movie.ReleaseYear < f.YearFrom {
+		return false
+	}
+	if f.YearTo != 0 && movie.ReleaseYear > f.YearTo {
+		return false
+	}
+	if f.TitlePrefix != "" && !strings.HasPrefix(normalizeTitle(movie.Title), normalizeTitle(f.TitlePrefix)) {
+		return false
+	}
+	if f.HasCover != nil && *f.HasCover != (movieCover(movie).Key != "") {
+		return false
+	}
+	if f.HasSummary != nil && *f.HasSummary != (movie.GeneratedSummary != "") {
+		return false
+	}
+	return true
+}
+
+// parseListQuery validates the query parameters of a movie list. The legacy
+// year parameter is a range of a single year.
+func parseListQuery(query map[string]string) (ListQuery, error) {
+	for name := range query {
+		if !slices.Contains(listParams, name) {
+			return ListQuery{}, badRequest("unknown query parameter '%v', expected one of: %v", name, strings.Join(listParams, ", "))
+		}
+	}
+
+	var list ListQuery
+	var err error
+
+	if list.Limit, err = parseLimit(query["limit"]); err != nil {
+		return ListQuery{}, err
+	}
+	list.Cursor = query["cursor"]
+
+	if list.Filter.Genre, err = parseGenreFilter(query["genre"]); err != nil {
+		return ListQuery{}, err
+	}
+
+	if year, ok := query["year"]; ok {
+		if year == "" {
+			return ListQuery{}, badRequest("year cannot be empty")
+		}
+		if query["yearFrom"] != "" || query["yearTo"] != "" {
+			return ListQuery{}, badRequest("year cannot be combined with yearFrom or yearTo")
+		}
+		if list.Filter.YearFrom, err = parseYear(year); err != nil {
+			return ListQuery{}, err
+		}
+		list.Filter.YearTo = list.Filter.YearFrom
+	} else {
+		if list.Filter.YearFrom, err = parseYear(query["yearFrom"]); err != nil {
+			return ListQuery{}, err
+		}
+		if list.Filter.YearTo, err = parseYear(query["yearTo"]); err != nil {
+			return ListQuery{}, err
+		}
+		if list.Filter.YearFrom != 0 && list.Filter.YearTo != 0 && list.Filter.YearFrom > list.Filter.YearTo {
+			return ListQuery{}, badRequest("yearFrom cannot be after yearTo")
+		}
+	}
+
+	if titlePrefix, ok := query["titlePrefix"]; ok {
+		list.Filter.TitlePrefix = strings.TrimSpace(titlePrefix)
+		if list.Filter.TitlePrefix == "" || len(list.Filter.TitlePrefix) > maxTitlePrefixLength {
+			return ListQuery{}, badRequest("titlePrefix must be between 1 and %d characters", maxTitlePrefixLength)
+		}
+	}
+
+	if list.Filter.HasCover, err = parseBoolParam(query, "hasCover"); err != nil {
+		return ListQuery{}, err
+	}
+	if list.Filter.HasSummary, err = parseBoolParam(query, "hasSummary"); err != nil {
+		return ListQuery{}, err
+	}
+
+	if fields, ok := query["fields"]; ok {
+		if list.Fields, err = parseFields(fields); err != nil {
+			return ListQuery{}, err
+		}
+	}
+
+	if sort, ok := query["sort"]; ok {
+		if !slices.Contains(listSorts, strings.TrimPrefix(sort, "-")) {
+			return ListQuery{}, badRequest("sort must be one of: %v, optionally prefixed with '-'", strings.Join(listSorts, ", "))
+		}
+		list.Sort = sort
+	}
+	return list, nil
+}
+
+// parseFields reads the comma separated fields parameter.
+func parseFields(fields string) ([]string, error) {
+	var parsed []string
+	for _, field := range strings.Split(fields, ",") {
+		field = strings.TrimSpace(field)
+		if !slices.Contains(movieFields, field) {
+			return nil, badRequest("unknown field '%v' in fields, expected one of: %v", field, strings.Join(movieFields, ", "))
+		}
+		if !slices.Contains(parsed, field) {
+			parsed = append(parsed, field)
+		}
+	}
+	return parsed, nil
+}
+
+// projection returns the attributes the store has to read for the list: the
+// requested fields plus those the filters, sort and cursors are computed
+// from. It is nil when every attribute is needed.
+func (l ListQuery) projection() []string {
+	if len(l.Fields) == 0 {
+		return nil
+	}
+
+	projection := slices.Clone(l.Fields)
+	need := func(field string) {
+		if !slices.Contains(projection, field) {
+			projection = append(projection, field)
+		}
+	}
+
+	need("movieId")
+	if l.Filter.Genre != "" {
+		need("genre")
+	}
+	if l.Filter.hasYear() || l.Sort != "" {
+		need("releaseYear")
+	}
+	if l.Filter.TitlePrefix != "" {
+		need("title")
+	}
+	if l.Filter.HasCover != nil {
+		need("coverUrl")
+	}
+	if l.Filter.HasSummary != nil {
+		need("generatedSummary")
+	}
+	return projection
+}
+
+// selectFields renders movies with only the given fields, or whole when
+// fields is empty.
+func selectFields(movies []Movie, fields []string) (any, error) {
+	if len(fields) == 0 {
+		return movies, nil
+	}
+
+	selected := make([]map[string]json.RawMessage, 0, len(movies))
+	for _, movie := range movies {
+		movieJson, err := json.Marshal(movie)
+		if err != nil {
+			return nil, err
+		}
+		var all map[string]json.RawMessage
+		if err := json.Unmarshal(movieJson, &all); err != nil {
+			return nil, err
+		}
+
+		movieFields := make(map[string]json.RawMessage, len(fields))
+		for _, field := range fields {
+			if value, ok := all[field]; ok {
+				movieFields[field] = value
+			}
+		}
+		selected = append(selected, movieFields)
+	}
+	return selected, nil
+}
+
+// parseBoolParam reads an optional true/false query parameter.
+func parseBoolParam(query map[string]string, name string) (*bool, error) {
+	value, ok := query[name]
+	if !ok {
+		return nil, nil
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, badRequest("%v must be true or false", name)
+	}
+	return &parsed, nil
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseListQuery(t *testing.T) {
+	list, err := parseListQuery(map[string]string{"yearFrom": "1990", "genre": "crime"})
+	if err != nil {
+		t.Fatalf("parseListQuery: %v", err)
+	}
+	if list.Sort != "" || list.Filter.Genre != "Crime" || list.Filter.YearFrom != 1990 || list.Limit != defaultPageSize {
+		t.Errorf("parsed %+v", list)
+	}
+
+	if _, err := parseListQuery(map[string]string{"year": "1995", "yearTo": "2000"}); err == nil {
+		t.Error("year combined with yearTo accepted")
+	}
+}
+
+func TestListQueryProjection(t *testing.T) {
+	list, err := parseListQuery(map[string]string{"fields": "coverUrl", "genre": "Drama", "sort": "-releaseYear"})
+	if err != nil {
+		t.Fatalf("parseListQuery: %v", err)
+	}
+
+	want := []string{"coverUrl", "movieId", "genre", "releaseYear"}
+	if projection := list.projection(); !slices.Equal(projection, want) {
+		t.Errorf("projection = %v, want %v", projection, want)
+	}
+	if projection := (ListQuery{}).projection(); projection != nil {
+		t.Errorf("projection without fields = %v, want every attribute", projection)
+	}
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+const redactedValue = "[REDACTED]"
+
+// sensitiveHeaders are never written to the logs, whatever their case.
+var sensitiveHeaders = map[string]bool{
+	"authorization":        true,
+	"proxy-authorization":  true,
+	"cookie":               true,
+	"set-cookie":           true,
+	"x-api-key":            true,
+	"x-amz-security-token": true,
+}
+
+type loggerKey struct{}
+
+// NewLogger returns a JSON logger writing to stdout at the given level
+// (debug, info, warn or error). Unknown or empty levels fall back to info.
+func NewLogger(level string) *slog.Logger {
+	var logLevel slog.Level
+	if err := logLevel.UnmarshalText([]byte(strings.TrimSpace(level))); err != nil {
+		logLevel = slog.LevelInfo
+	}
+	return slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: logLevel}))
+}
+
+// withLogger returns a copy of ctx carrying logger.
+func withLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// loggerFrom returns the request scoped logger stored in ctx, or the default
+// logger outside of a request.
+func loggerFrom(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// redactHeaders returns a copy of headers that is safe to log.
+func redactHeaders(headers map[string]string) map[string]string {
+	redacted := make(map[string]string, len(headers))
+	for name, value := range headers {
+		if sensitiveHeaders[strings.ToLower(name)] {
+			value = redactedValue
+		}
+		redacted[name] = value
+	}
+	return redacted
+}
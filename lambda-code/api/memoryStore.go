@@ -0,0 +1,248 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// MemoryStore is a MovieStore that keeps movies in a map. It is used by the
+// tests and for running the API locally without DynamoDB.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	movies map[string]Movie
+	// titles maps normalized titles to the movieId that owns them.
+	titles map[string]string
+}
+
+func NewMemoryStore(movies ...Movie) *MemoryStore {
+	store := &MemoryStore{
+		movies: make(map[string]Movie),
+		titles: make(map[string]string),
+	}
+	for _, movie := range movies {
+		store.movies[movie.MovieId] = movie
+		store.titles[normalizeTitle(movie.Title)] = movie.MovieId
+	}
+	return store
+}
+
+// sortedMovies returns the stored movies ordered by movieId so that pages are
+// stable between calls.
+func (s *MemoryStore) sortedMovies() []Movie {
+	movies := make([]Movie, 0, len(s.movies))
+	for _, movie := range s.movies {
+		movies = append(movies, movie)
+	}
+	slices.SortFunc(movies, func(a, b Movie) int {
+		return strings.Compare(a.MovieId, b.MovieId)
+	})
+	return movies
+}
+
+// GetAllMovies returns whole movies whatever fields asks for, the handler
+// drops the fields that were not requested. Like DynamoStore, it orders a
+// list bounded by release year by year.
+func (s *MemoryStore) GetAllMovies(ctx context.Context, limit int32, cursor string, filter MovieFilter, sort string, fields []string) ([]Movie, string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// position orders the list, the cursor is the position of the last movie
+	// of the previous page.
+	byYear, descending := filter.hasYear() || sort != "", strings.HasPrefix(sort, "-")
+	position := func(movie Movie) string {
+		if byYear {
+			return fmt.Sprintf("%05d %s", movie.ReleaseYear, movie.MovieId)
+		}
+		return movie.MovieId
+	}
+	after := func(movie Movie, last string) bool {
+		if descending {
+			return position(movie) < last
+		}
+		return position(movie) > last
+	}
+
+	var startAfter string
+	if cursor != "" {
+		last, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil || len(last) == 0 {
+			return nil, "", badRequest("invalid cursor")
+		}
+		startAfter = string(last)
+	}
+
+	all := s.sortedMovies()
+	slices.SortStableFunc(all, func(a, b Movie) int {
+		return strings.Compare(position(a), position(b))
+	})
+	if descending {
+		slices.Reverse(all)
+	}
+
+	var movies []Movie
+	for _, movie := range all {
+		if startAfter != "" && !after(movie, startAfter) {
+			continue
+		}
+		if !filter.matches(movie) {
+			continue
+		}
+		if len(movies) == int(limit) {
+			last := position(movies[len(movies)-1])
+			return movies, base64.RawURLEncoding.EncodeToString([]byte(last)), nil
+		}
+		movies = append(movies, movie)
+	}
+	return movies, "", nil
+}
+
+func (s *MemoryStore) CountGenres(ctx context.Context) (map[string]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := map[string]int{}
+	for _, movie := range s.movies {
+		for _, genre := range movie.Genre {
+			counts[genre]++
+		}
+	}
+	return counts, nil
+}
+
+func (s *MemoryStore) SearchMovies(ctx context.Context, terms []string, limit int32) ([]SearchResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var results []SearchResult
+	for _, movie := range s.movies {
+		tokens := searchTokens(movie)
+
+		termMatches := make([]map[string]int, len(terms))
+		for i, term := range terms {
+			termMatches[i] = map[string]int{}
+			if weight, ok := tokens[term]; ok {
+				termMatches[i][movie.MovieId] = weight
+			}
+		}
+
+		if score, ok := scoreMatches(termMatches)[movie.MovieId]; ok {
+			results = append(results, SearchResult{Movie: movie, Score: score})
+		}
+	}
+
+	sortSearchResults(results)
+	if len(results) > int(limit) {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+func (s *MemoryStore) GetMovieById(ctx context.Context, movieId string) (Movie, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	movie, ok := s.movies[movieId]
+	if !ok {
+		return Movie{}, ErrMovieNotFound
+	}
+	return movie, nil
+}
+
+func (s *MemoryStore) GetMoviesByIds(ctx context.Context, movieIds []string) ([]Movie, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var movies []Movie
+	for _, movieId := range movieIds {
+		if movie, ok := s.movies[movieId]; ok {
+			movies = append(movies, movie)
+		}
+	}
+	return movies, nil
+}
+
+func (s *MemoryStore) AddMovie(ctx context.Context, movie Movie) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.titles[normalizeTitle(movie.Title)]; ok {
+		return ErrTitleExists
+	}
+
+	movie.Version = 1
+	s.movies[movie.MovieId] = movie
+	s.titles[normalizeTitle(movie.Title)] = movie.MovieId
+	return nil
+}
+
+func (s *MemoryStore) UpdateMovieById(ctx context.Context, movieId string, movie Movie, expectedVersion *int64) (Movie, error) {
+	return s.PatchMovieById(ctx, movieId, replacePatch(movie), expectedVersion)
+}
+
+func (s *MemoryStore) PatchMovieById(ctx context.Context, movieId string, patch MoviePatch, expectedVersion *int64) (Movie, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.movies[movieId]
+	if !ok {
+		return Movie{}, ErrMovieNotFound
+	}
+
+	if expectedVersion != nil && *expectedVersion != existing.Version {
+		return Movie{}, ErrVersionMismatch
+	}
+
+	if patch.isEmpty() {
+		return existing, nil
+	}
+
+	if patch.Title != nil {
+		oldTitle, newTitle := normalizeTitle(existing.Title), normalizeTitle(*patch.Title)
+		if oldTitle != newTitle {
+			if _, ok := s.titles[newTitle]; ok {
+				return Movie{}, ErrTitleExists
+			}
+			delete(s.titles, oldTitle)
+			s.titles[newTitle] = movieId
+		}
+	}
+
+	updated := patch.apply(existing)
+	s.movies[movieId] = updated
+	return updated, nil
+}
+
+func (s *MemoryStore) UpdateMovieSummary(ctx context.Context, movieId string, summary string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	movie, ok := s.movies[movieId]
+	if !ok {
+		return ErrMovieNotFound
+	}
+	movie.GeneratedSummary = summary
+	movie.Version++
+	s.movies[movieId] = movie
+	return nil
+}
+
+func (s *MemoryStore) DeleteMovieById(ctx context.Context, movieId string, expectedVersion *int64) (Movie, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	movie, ok := s.movies[movieId]
+	if !ok {
+		return Movie{}, ErrMovieNotFound
+	}
+
+	if expectedVersion != nil && *expectedVersion != movie.Version {
+		return Movie{}, ErrVersionMismatch
+	}
+	delete(s.movies, movieId)
+	delete(s.titles, normalizeTitle(movie.Title))
+	return movie, nil
+}
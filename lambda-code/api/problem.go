@@ -0,0 +1,98 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const problemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object. Clients opt in to it with
+// an Accept header listing application/problem+json; everyone else keeps
+// getting errors in the Response envelope.
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// problemTypes identifies each error kind with a stable URI so clients can
+// branch on the type rather than the human readable detail.
+var problemTypes = []struct {
+	kind    error
+	typeUri string
+}{
+	{ErrBadRequest, "urn:movies-api:problem:bad-request"},
+	{ErrNotFound, "urn:movies-api:problem:not-found"},
+	{ErrMethodNotAllowed, "urn:movies-api:problem:method-not-allowed"},
+	{ErrConflict, "urn:movies-api:problem:conflict"},
+	{ErrPreconditionFailed, "urn:movies-api:problem:precondition-failed"},
+	{ErrPayloadTooLarge, "urn:movies-api:problem:payload-too-large"},
+	{ErrValidation, "urn:movies-api:problem:validation-error"},
+	{ErrUpstream, "urn:movies-api:problem:upstream-unavailable"},
+}
+
+func problemType(err error) string {
+	for _, problemType := range problemTypes {
+		if errors.Is(err, problemType.kind) {
+			return problemType.typeUri
+		}
+	}
+	return "about:blank"
+}
+
+// acceptsProblemJson reports whether the Accept header asks for
+// application/problem+json with a non-zero quality.
+func acceptsProblemJson(headers map[string]string) bool {
+	for _, accepted := range strings.Split(getHeaders(headers, "Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil || mediaType != problemContentType {
+			continue
+		}
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil && q == 0 {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+func problemResponse(statusCode int, detail string, err error, instance string) events.APIGatewayProxyResponse {
+	problem := Problem{
+		Type:     problemType(err),
+		Title:    http.StatusText(statusCode),
+		Status:   statusCode,
+		Detail:   detail,
+		Instance: instance,
+	}
+
+	var validation *ValidationError
+	if errors.As(err, &validation) {
+		problem.Errors = validation.Errors
+	}
+
+	jsonRes, err := json.Marshal(problem)
+	if err != nil {
+		slog.Error("unable to marshal problem", "error", err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       err.Error(),
+		}
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: statusCode,
+		Headers:    map[string]string{"Content-Type": problemContentType},
+		Body:       string(jsonRes),
+	}
+}
@@ -0,0 +1,397 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"maps"
+	"mime"
+	"mime/multipart"
+	"slices"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+)
+
+// movieInput holds the client supplied fields of a movie for POST and PUT,
+// whichever body format they arrived in.
+type movieInput struct {
+	Title       string
+	ReleaseYear uint16
+	Genre       Genres
+	// CoverImage is only set for multipart bodies. JSON clients upload covers
+	// separately with PUT /api/movies/{movieId}/cover.
+	CoverImage *CoverImage
+}
+
+// readMovieInput parses the body of a POST or PUT request. multipart/form-data
+// and application/json bodies are accepted. Multipart bodies larger than
+// config.MaxUploadBytes are rejected and a cover image has to pass readCover.
+func readMovieInput(event events.APIGatewayProxyRequest, config Config) (movieInput, error) {
+	contentType := getHeaders(event.Headers, "Content-Type")
+	if contentType == "" {
+		return movieInput{}, badRequest("Missing Content-Type header")
+	}
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return movieInput{}, badRequest("Invalid or unsupported Content-Type")
+	}
+
+	switch mediaType {
+	case "multipart/form-data":
+		form, err := readMultipartForm(event, params["boundary"], config.MaxUploadBytes)
+		if err != nil {
+			return movieInput{}, err
+		}
+		return movieInputFromForm(form, config)
+	case "application/json":
+		body, err := requestBody(event)
+		if err != nil {
+			return movieInput{}, err
+		}
+		return movieInputFromJson(body)
+	}
+
+	return movieInput{}, badRequest("Invalid or unsupported Content-Type")
+}
+
+// readCoverImage parses the multipart/form-data body of a cover upload and
+// returns its validated coverImage file.
+func readCoverImage(event events.APIGatewayProxyRequest, config Config) (*CoverImage, error) {
+	mediaType, params, err := mime.ParseMediaType(getHeaders(event.Headers, "Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" {
+		return nil, badRequest("Cover images must be uploaded as multipart/form-data")
+	}
+
+	form, err := readMultipartForm(event, params["boundary"], config.MaxUploadBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(form.File["coverImage"]) == 0 {
+		var validation ValidationError
+		validation.Add("coverImage", "'coverImage' field is required")
+		return nil, validation.Err()
+	}
+	return readCover(form.File["coverImage"][0], config.MaxCoverBytes, config.MaxCoverDimension)
+}
+
+func readMultipartForm(event events.APIGatewayProxyRequest, boundary string, maxUploadBytes int64) (*multipart.Form, error) {
+	if boundary == "" {
+		return nil, badRequest("Missing boundary in Content-Type header")
+	}
+
+	// multipart/form-data is registered as a binary media type on the API
+	// Gateway, so the body always arrives base64 encoded.
+	bodyBytes, err := base64.StdEncoding.DecodeString(event.Body)
+	if err != nil {
+		return nil, badRequest("failed to decode body: %v", err)
+	}
+
+	if int64(len(bodyBytes)) > maxUploadBytes {
+		return nil, newError(ErrPayloadTooLarge, "Request body cannot be larger than %d bytes", maxUploadBytes)
+	}
+
+	bytesReader := bytes.NewReader(bodyBytes)
+	multipartReader := multipart.NewReader(bytesReader, boundary)
+	form, err := multipartReader.ReadForm(maxUploadBytes)
+	if err != nil {
+		return nil, badRequest("Error parsing form data: %v", err)
+	}
+
+	return form, nil
+}
+
+// requestBody returns the raw body, decoding it first when API Gateway has
+// base64 encoded it.
+func requestBody(event events.APIGatewayProxyRequest) ([]byte, error) {
+	if !event.IsBase64Encoded {
+		return []byte(event.Body), nil
+	}
+
+	bodyBytes, err := base64.StdEncoding.DecodeString(event.Body)
+	if err != nil {
+		return nil, badRequest("failed to decode body: %v", err)
+	}
+	return bodyBytes, nil
+}
+
+func movieInputFromForm(form *multipart.Form, config Config) (movieInput, error) {
+	var validation ValidationError
+	var input movieInput
+
+	input.Title = formValue(form, "title")
+	if input.Title == "" {
+		validation.Add("title", "'title' field is required")
+	}
+
+	// Genres may be sent as repeated genre fields or comma separated.
+	var genres Genres
+	for _, value := range form.Value["genre"] {
+		genres = append(genres, splitGenres(value)...)
+	}
+	input.Genre = parseGenres(genres, "genre", &validation)
+
+	if releaseYear := formValue(form, "releaseYear"); releaseYear == "" {
+		validation.Add("releaseYear", "'releaseYear' field is required")
+	} else if year, err := strconv.ParseUint(releaseYear, 10, 16); err != nil || year == 0 {
+		validation.Add("releaseYear", "'releaseYear' field must be a valid year")
+	} else {
+		input.ReleaseYear = uint16(year)
+	}
+
+	if err := validation.Err(); err != nil {
+		return movieInput{}, err
+	}
+
+	// check if movie image is provided
+	if len(form.File) != 0 && len(form.File["coverImage"]) != 0 {
+		cover, err := readCover(form.File["coverImage"][0], config.MaxCoverBytes, config.MaxCoverDimension)
+		if err != nil {
+			return movieInput{}, err
+		}
+		input.CoverImage = cover
+	}
+
+	return input, nil
+}
+
+// formValue returns the first value of a form field, or "" when it is missing.
+func formValue(form *multipart.Form, field string) string {
+	if len(form.Value[field]) == 0 {
+		return ""
+	}
+	return form.Value[field][0]
+}
+
+// movieInputFromJson decodes a JSON body into a Movie. Unknown fields are
+// rejected, as are the fields the API manages itself.
+func movieInputFromJson(body []byte) (movieInput, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+
+	var movie Movie
+	if err := decoder.Decode(&movie); err != nil {
+		return movieInput{}, badRequest("Error parsing JSON body: %v", err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return movieInput{}, badRequest("Error parsing JSON body: unexpected data after JSON object")
+	}
+
+	var validation ValidationError
+	if movie.MovieId != "" {
+		validation.Add("movieId", "'movieId' field cannot be set")
+	}
+	if movie.CoverUrl != "" {
+		validation.Add("coverUrl", "'coverUrl' field cannot be set, upload a coverImage instead")
+	}
+	if movie.Covers != nil {
+		validation.Add("covers", "'covers' field cannot be set, upload a coverImage instead")
+	}
+	if movie.GeneratedSummary != "" {
+		validation.Add("generatedSummary", "'generatedSummary' field cannot be set")
+	}
+	if movie.Title == "" {
+		validation.Add("title", "'title' field is required")
+	}
+	if movie.ReleaseYear == 0 {
+		validation.Add("releaseYear", "'releaseYear' field is required")
+	}
+	genres := parseGenres(movie.Genre, "genre", &validation)
+	if err := validation.Err(); err != nil {
+		return movieInput{}, err
+	}
+
+	return movieInput{
+		Title:       movie.Title,
+		ReleaseYear: movie.ReleaseYear,
+		Genre:       genres,
+	}, nil
+}
+
+// readMovieIds parses the {"movieIds": [...]} body of a batch get. Repeated
+// ids are dropped.
+func readMovieIds(event events.APIGatewayProxyRequest) ([]string, error) {
+	var input struct {
+		MovieIds []string `json:"movieIds"`
+	}
+	if err := readJsonBody(event, &input); err != nil {
+		return nil, err
+	}
+
+	var validation ValidationError
+	var movieIds []string
+	for _, movieId := range input.MovieIds {
+		if movieId == "" {
+			validation.Add("movieIds", "'movieIds' cannot contain an empty id")
+			continue
+		}
+		if !slices.Contains(movieIds, movieId) {
+			movieIds = append(movieIds, movieId)
+		}
+	}
+	if len(input.MovieIds) == 0 {
+		validation.Add("movieIds", "'movieIds' field is required")
+	} else if len(input.MovieIds) > maxBatchGetIds {
+		validation.Add("movieIds", "'movieIds' cannot contain more than %d ids", maxBatchGetIds)
+	}
+	if err := validation.Err(); err != nil {
+		return nil, err
+	}
+	return movieIds, nil
+}
+
+// coverUploadInput is the body of a request for a cover upload URL.
+type coverUploadInput struct {
+	ContentType   string `json:"contentType"`
+	ContentLength int64  `json:"contentLength"`
+}
+
+// readCoverUpload parses the body of POST /api/movies/{movieId}/cover/upload-url.
+// The content type has to be one of coverTypes and the length within
+// config.MaxCoverBytes, as S3 will only accept an upload matching both.
+func readCoverUpload(event events.APIGatewayProxyRequest, config Config) (coverUploadInput, error) {
+	var input coverUploadInput
+	if err := readJsonBody(event, &input); err != nil {
+		return coverUploadInput{}, err
+	}
+
+	var validation ValidationError
+	if !isCoverContentType(input.ContentType) {
+		validation.Add("contentType", "'contentType' must be one of image/jpeg, image/png or image/webp")
+	}
+	if input.ContentLength <= 0 {
+		validation.Add("contentLength", "'contentLength' field is required")
+	}
+	if err := validation.Err(); err != nil {
+		return coverUploadInput{}, err
+	}
+	if input.ContentLength > config.MaxCoverBytes {
+		return coverUploadInput{}, newError(ErrPayloadTooLarge, "coverImage cannot be larger than %d bytes", config.MaxCoverBytes)
+	}
+	return input, nil
+}
+
+// readUploadId parses the {"uploadId": "..."} body confirming a cover
+// upload.
+func readUploadId(event events.APIGatewayProxyRequest) (string, error) {
+	var input struct {
+		UploadId string `json:"uploadId"`
+	}
+	if err := readJsonBody(event, &input); err != nil {
+		return "", err
+	}
+
+	if _, err := uuid.Parse(input.UploadId); err != nil {
+		var validation ValidationError
+		validation.Add("uploadId", "'uploadId' must be the id returned with the upload URL")
+		return "", validation.Err()
+	}
+	return input.UploadId, nil
+}
+
+// readJsonBody decodes an application/json body into v, rejecting unknown
+// fields.
+func readJsonBody(event events.APIGatewayProxyRequest, v any) error {
+	mediaType, _, err := mime.ParseMediaType(getHeaders(event.Headers, "Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return badRequest("Invalid or unsupported Content-Type, expected application/json")
+	}
+
+	body, err := requestBody(event)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(v); err != nil {
+		return badRequest("Error parsing JSON body: %v", err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return badRequest("Error parsing JSON body: unexpected data after JSON object")
+	}
+	return nil
+}
+
+// readMoviePatch parses a JSON Merge Patch (RFC 7396) body for PATCH. Fields
+// that are present are updated, and coverUrl, covers or generatedSummary set
+// to null are removed from the movie. The cover and its variants go together,
+// so either of coverUrl and covers removes both.
+func readMoviePatch(event events.APIGatewayProxyRequest) (MoviePatch, error) {
+	contentType := getHeaders(event.Headers, "Content-Type")
+	if contentType == "" {
+		return MoviePatch{}, badRequest("Missing Content-Type header")
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || (mediaType != "application/merge-patch+json" && mediaType != "application/json") {
+		return MoviePatch{}, badRequest("Invalid or unsupported Content-Type")
+	}
+
+	body, err := requestBody(event)
+	if err != nil {
+		return MoviePatch{}, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil || fields == nil {
+		return MoviePatch{}, badRequest("Error parsing JSON body: body must be a JSON object")
+	}
+
+	var patch MoviePatch
+	var validation ValidationError
+	// Walk the fields in a fixed order so validation errors are stable.
+	for _, name := range slices.Sorted(maps.Keys(fields)) {
+		value := fields[name]
+		isNull := string(value) == "null"
+
+		switch name {
+		case "title":
+			if isNull {
+				validation.Add(name, "'title' field cannot be removed")
+			} else if err := json.Unmarshal(value, &patch.Title); err != nil || *patch.Title == "" {
+				validation.Add(name, "'title' field must be a non-empty string")
+			}
+		case "releaseYear":
+			if isNull {
+				validation.Add(name, "'releaseYear' field cannot be removed")
+			} else if err := json.Unmarshal(value, &patch.ReleaseYear); err != nil || *patch.ReleaseYear == 0 {
+				validation.Add(name, "'releaseYear' field must be a valid year")
+			}
+		case "genre":
+			if isNull {
+				validation.Add(name, "'genre' field cannot be removed")
+			} else if err := json.Unmarshal(value, &patch.Genre); err != nil {
+				validation.Add(name, "'genre' field must be a list of genres")
+			} else {
+				genres := parseGenres(*patch.Genre, name, &validation)
+				patch.Genre = &genres
+			}
+		case "coverUrl", "covers":
+			if !isNull {
+				validation.Add(name, "'%v' field can only be set to null, upload a coverImage instead", name)
+			}
+			patch.RemoveCover = true
+		case "generatedSummary":
+			if isNull {
+				patch.RemoveGeneratedSummary = true
+			} else if err := json.Unmarshal(value, &patch.GeneratedSummary); err != nil {
+				validation.Add(name, "'generatedSummary' field must be a string")
+			}
+		case "movieId":
+			validation.Add(name, "'movieId' field cannot be set")
+		default:
+			return MoviePatch{}, badRequest("Error parsing JSON body: unknown field %q", name)
+		}
+	}
+
+	if err := validation.Err(); err != nil {
+		return MoviePatch{}, err
+	}
+	return patch, nil
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// HandlerFunc serves one API Gateway proxy event. Errors are rendered by
+// errorResponse, so handlers return them rather than building error bodies.
+type HandlerFunc func(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+// Middleware wraps every request served by a Router, including requests that
+// end in a 404 or 405.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Router dispatches events on their method and path. Patterns are made of
+// literal segments and {name} parameters, e.g. /api/movies/{movieId}/summary;
+// matched parameters are passed to the handler in event.PathParameters. When
+// a path matches several patterns the one with the most literal segments
+// wins, so /api/movies/summary is not taken for a movieId.
+type Router struct {
+	routes     []*route
+	middleware []Middleware
+}
+
+type route struct {
+	segments []string
+	// methods lists the served methods in registration order for the Allow
+	// header.
+	methods  []string
+	handlers map[string]HandlerFunc
+}
+
+func NewRouter() *Router {
+	return &Router{}
+}
+
+// Handle registers handler for method requests to pattern.
+func (r *Router) Handle(method string, pattern string, handler HandlerFunc) {
+	segments := splitPath(pattern)
+	for _, existing := range r.routes {
+		if strings.Join(existing.segments, "/") == strings.Join(segments, "/") {
+			existing.add(method, handler)
+			return
+		}
+	}
+
+	route := &route{segments: segments, handlers: map[string]HandlerFunc{}}
+	route.add(method, handler)
+	r.routes = append(r.routes, route)
+}
+
+// Use appends middleware. The first middleware added is the outermost.
+func (r *Router) Use(middleware ...Middleware) {
+	r.middleware = append(r.middleware, middleware...)
+}
+
+// ServeEvent runs event through the middleware and the matching handler. It
+// returns ErrNotFound when no pattern matches the path and a
+// MethodNotAllowedError when the path is served but not for the method.
+func (r *Router) ServeEvent(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	next := r.dispatch
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		next = r.middleware[i](next)
+	}
+	return next(ctx, event)
+}
+
+func (r *Router) dispatch(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	route, params := r.match(event.Path)
+	if route == nil {
+		return events.APIGatewayProxyResponse{}, newError(ErrNotFound, "No route for %v %v", event.HTTPMethod, event.Path)
+	}
+
+	handler, ok := route.handlers[event.HTTPMethod]
+	if !ok {
+		return events.APIGatewayProxyResponse{}, &MethodNotAllowedError{Method: event.HTTPMethod, Allow: route.methods}
+	}
+
+	event.PathParameters = params
+	return handler(ctx, event)
+}
+
+func (r *Router) match(path string) (*route, map[string]string) {
+	segments := splitPath(path)
+
+	var best *route
+	var bestParams map[string]string
+	bestLiterals := -1
+
+	for _, route := range r.routes {
+		params, literals, ok := route.match(segments)
+		if ok && literals > bestLiterals {
+			best, bestParams, bestLiterals = route, params, literals
+		}
+	}
+	return best, bestParams
+}
+
+func (rt *route) add(method string, handler HandlerFunc) {
+	if _, ok := rt.handlers[method]; !ok {
+		rt.methods = append(rt.methods, method)
+	}
+	rt.handlers[method] = handler
+}
+
+// match reports whether segments fit the route, returning the parameter
+// values and how many literal segments matched.
+func (rt *route) match(segments []string) (map[string]string, int, bool) {
+	if len(segments) != len(rt.segments) {
+		return nil, 0, false
+	}
+
+	params := map[string]string{}
+	literals := 0
+	for i, segment := range rt.segments {
+		if name, ok := strings.CutPrefix(segment, "{"); ok {
+			if segments[i] == "" {
+				return nil, 0, false
+			}
+			params[strings.TrimSuffix(name, "}")] = segments[i]
+			continue
+		}
+		if segment != segments[i] {
+			return nil, 0, false
+		}
+		literals++
+	}
+	return params, literals, true
+}
+
+// splitPath splits a path into its segments, ignoring a trailing slash.
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestRouter(t *testing.T) {
+	served := func(name string) HandlerFunc {
+		return func(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			return events.APIGatewayProxyResponse{Body: name + " " + event.PathParameters["movieId"]}, nil
+		}
+	}
+
+	r := NewRouter()
+	r.Handle("GET", "/api/movies/{movieId}", served("movie"))
+	r.Handle("DELETE", "/api/movies/{movieId}", served("delete"))
+	r.Handle("GET", "/api/movies/summary", served("summary"))
+	r.Handle("GET", "/api/movies/{movieId}/summary", served("movie summary"))
+
+	tests := []struct {
+		method, path string
+		wantBody     string
+		wantErr      error
+	}{
+		{"GET", "/api/movies/42", "movie 42", nil},
+		{"GET", "/api/movies/summary", "summary ", nil},
+		{"GET", "/api/movies/42/summary", "movie summary 42", nil},
+		{"DELETE", "/api/movies/42/", "delete 42", nil},
+		{"POST", "/api/movies/42", "", ErrMethodNotAllowed},
+		{"GET", "/api/movies/42/cast", "", ErrNotFound},
+		{"GET", "/api/movies//summary", "", ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			res, err := r.ServeEvent(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: tt.method, Path: tt.path})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if res.Body != tt.wantBody {
+				t.Errorf("body = %q, want %q", res.Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestRouterMiddleware(t *testing.T) {
+	var calls []string
+	middleware := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+				calls = append(calls, name)
+				return next(ctx, event)
+			}
+		}
+	}
+
+	r := NewRouter()
+	r.Use(middleware("outer"), middleware("inner"))
+	r.Handle("GET", "/api/movies", func(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		calls = append(calls, "handler")
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+	})
+
+	r.ServeEvent(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/api/movies"})
+	r.ServeEvent(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/api/actors"})
+
+	want := []string{"outer", "inner", "handler", "outer", "inner"}
+	if !slices.Equal(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
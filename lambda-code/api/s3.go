@@ -0,0 +1,293 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// CoverStore stores movie cover images. Failures talking to the bucket are
+// returned as an UpstreamError.
+type CoverStore interface {
+	// PutObject stores body under objectKey.
+	PutObject(ctx context.Context, objectKey string, body []byte, contentType string) error
+	// ObjectUrl returns the URL clients fetch objectKey from.
+	ObjectUrl(ctx context.Context, objectKey string) (string, error)
+	// DeleteObjects removes the objects, ignoring keys that do not exist.
+	DeleteObjects(ctx context.Context, objectKeys []string) error
+
+	// Direct uploads are staged under their own keys, outside the folder the
+	// covers are served from, until they have been validated.
+
+	// PresignUpload returns a request the client can send for expires to
+	// store exactly contentLength bytes of contentType under uploadKey.
+	PresignUpload(ctx context.Context, uploadKey string, contentType string, contentLength int64, expires time.Duration) (PresignedUpload, error)
+	// HeadUpload describes the object uploaded under uploadKey, or returns
+	// ErrNotFound when nothing has been uploaded.
+	HeadUpload(ctx context.Context, uploadKey string) (UploadedObject, error)
+	// GetUpload reads the object uploaded under uploadKey.
+	GetUpload(ctx context.Context, uploadKey string) ([]byte, error)
+	// DeleteUpload removes the object uploaded under uploadKey.
+	DeleteUpload(ctx context.Context, uploadKey string) error
+}
+
+// PresignedUpload is a presigned PUT request. The client has to send the
+// headers as given, they are part of the signature.
+type PresignedUpload struct {
+	Url       string            `json:"url"`
+	Method    string            `json:"method"`
+	Headers   map[string]string `json:"headers"`
+	ExpiresAt time.Time         `json:"expiresAt"`
+}
+
+// UploadedObject is what HeadUpload reports about a staged upload.
+type UploadedObject struct {
+	Size        int64
+	ContentType string
+}
+
+var errUploadNotFound = newError(ErrNotFound, "No cover has been uploaded, PUT it to the upload URL first")
+
+// S3CoverStore is the CoverStore backed by the movies S3 bucket.
+type S3CoverStore struct {
+	client     *s3.Client
+	bucketName string
+	region     string
+	// prefix is the folder in the bucket that holds the cover images and
+	// uploadPrefix the one direct uploads are staged in.
+	prefix       string
+	uploadPrefix string
+	endpoint     string
+	usePathStyle bool
+	// urlMode, baseUrl and urlTTL select how ObjectUrl renders URLs, see
+	// Config.CoverUrlMode.
+	urlMode string
+	baseUrl string
+	urlTTL  time.Duration
+}
+
+func NewS3CoverStore(cfg aws.Config, appConfig Config) *S3CoverStore {
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		o.BaseEndpoint = baseEndpoint(appConfig.S3Endpoint)
+		o.UsePathStyle = appConfig.S3UsePathStyle
+	})
+	return &S3CoverStore{
+		client:       client,
+		bucketName:   appConfig.BucketName,
+		region:       appConfig.Region,
+		prefix:       appConfig.ImagePrefix,
+		uploadPrefix: appConfig.UploadPrefix,
+		endpoint:     strings.TrimSuffix(appConfig.S3Endpoint, "/"),
+		usePathStyle: appConfig.S3UsePathStyle,
+		urlMode:      appConfig.CoverUrlMode,
+		baseUrl:      strings.TrimSuffix(appConfig.CoverBaseUrl, "/"),
+		urlTTL:       appConfig.CoverUrlTTL,
+	}
+}
+
+func (s *S3CoverStore) PutObject(ctx context.Context, objectKey string, body []byte, contentType string) error {
+	key := fmt.Sprintf("%v/%v", s.prefix, objectKey)
+
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucketName),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String(contentType),
+	})
+
+	if err != nil {
+		return upstreamError("S3", err)
+	}
+
+	if err := s3.NewObjectExistsWaiter(s.client).Wait(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(key),
+	}, time.Minute); err != nil {
+		return upstreamError("S3", err)
+	}
+
+	return nil
+}
+
+func (s *S3CoverStore) ObjectUrl(ctx context.Context, objectKey string) (string, error) {
+	key := fmt.Sprintf("%v/%v", s.prefix, objectKey)
+
+	switch s.urlMode {
+	case "cdn":
+		return fmt.Sprintf("%s/%s", s.baseUrl, key), nil
+	case "presigned":
+		cache, _ := ctx.Value(coverUrlCacheKey{}).(*coverUrlCache)
+		if url, ok := cache.get(key); ok {
+			return url, nil
+		}
+
+		req, err := s3.NewPresignClient(s.client).PresignGetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(s.bucketName),
+			Key:    aws.String(key),
+		}, s3.WithPresignExpires(s.urlTTL))
+		if err != nil {
+			return "", upstreamError("S3", err)
+		}
+		cache.put(key, req.URL)
+		return req.URL, nil
+	}
+	return s.objectUrl(key), nil
+}
+
+type coverUrlCacheKey struct{}
+
+// coverUrlCache holds the presigned cover URLs generated while serving one
+// request, so a cover appearing several times in a response is signed once
+// and always links to the same URL. A nil cache caches nothing.
+type coverUrlCache struct {
+	mu   sync.Mutex
+	urls map[string]string
+}
+
+// withCoverUrlCache returns a copy of ctx carrying an empty coverUrlCache for
+// ObjectUrl to use.
+func withCoverUrlCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, coverUrlCacheKey{}, &coverUrlCache{urls: map[string]string{}})
+}
+
+func (c *coverUrlCache) get(key string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	url, ok := c.urls[key]
+	return url, ok
+}
+
+func (c *coverUrlCache) put(key string, url string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.urls[key] = url
+}
+
+// objectUrl returns the public URL of the object at key, matching the
+// addressing style the client was configured with.
+func (s *S3CoverStore) objectUrl(key string) string {
+	switch {
+	case s.endpoint != "":
+		return fmt.Sprintf("%s/%s/%s", s.endpoint, s.bucketName, key)
+	case s.usePathStyle:
+		return fmt.Sprintf("https://s3.%s.amazonaws.com/%s/%s", s.region, s.bucketName, key)
+	}
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.bucketName, s.region, key)
+}
+
+func (s *S3CoverStore) DeleteObjects(ctx context.Context, objectKeys []string) error {
+	if len(objectKeys) == 0 {
+		return nil
+	}
+
+	objects := make([]types.ObjectIdentifier, len(objectKeys))
+	for i, objectKey := range objectKeys {
+		objects[i] = types.ObjectIdentifier{Key: aws.String(fmt.Sprintf("%v/%v", s.prefix, objectKey))}
+	}
+
+	result, err := s.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+		Bucket: aws.String(s.bucketName),
+		Delete: &types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+	})
+	if err != nil {
+		return upstreamError("S3", err)
+	}
+	if len(result.Errors) > 0 {
+		failed := result.Errors[0]
+		return upstreamError("S3", fmt.Errorf("unable to delete %v: %v", aws.ToString(failed.Key), aws.ToString(failed.Message)))
+	}
+	return nil
+}
+
+func (s *S3CoverStore) PresignUpload(ctx context.Context, uploadKey string, contentType string, contentLength int64, expires time.Duration) (PresignedUpload, error) {
+	req, err := s3.NewPresignClient(s.client).PresignPutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(s.bucketName),
+		Key:           aws.String(fmt.Sprintf("%v/%v", s.uploadPrefix, uploadKey)),
+		ContentType:   aws.String(contentType),
+		ContentLength: aws.Int64(contentLength),
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return PresignedUpload{}, upstreamError("S3", err)
+	}
+
+	headers := map[string]string{}
+	for name, values := range req.SignedHeader {
+		// Host is set by every HTTP client from the URL.
+		if name != "Host" {
+			headers[name] = strings.Join(values, ",")
+		}
+	}
+
+	return PresignedUpload{
+		Url:       req.URL,
+		Method:    req.Method,
+		Headers:   headers,
+		ExpiresAt: time.Now().Add(expires).UTC(),
+	}, nil
+}
+
+func (s *S3CoverStore) HeadUpload(ctx context.Context, uploadKey string) (UploadedObject, error) {
+	result, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(fmt.Sprintf("%v/%v", s.uploadPrefix, uploadKey)),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return UploadedObject{}, errUploadNotFound
+		}
+		return UploadedObject{}, upstreamError("S3", err)
+	}
+
+	return UploadedObject{
+		Size:        aws.ToInt64(result.ContentLength),
+		ContentType: aws.ToString(result.ContentType),
+	}, nil
+}
+
+func (s *S3CoverStore) GetUpload(ctx context.Context, uploadKey string) ([]byte, error) {
+	result, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(fmt.Sprintf("%v/%v", s.uploadPrefix, uploadKey)),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, errUploadNotFound
+		}
+		return nil, upstreamError("S3", err)
+	}
+	defer result.Body.Close()
+
+	data, err := io.ReadAll(result.Body)
+	if err != nil {
+		return nil, upstreamError("S3", err)
+	}
+	return data, nil
+}
+
+func (s *S3CoverStore) DeleteUpload(ctx context.Context, uploadKey string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(fmt.Sprintf("%v/%v", s.uploadPrefix, uploadKey)),
+	})
+	if err != nil {
+		return upstreamError("S3", err)
+	}
+	return nil
+}
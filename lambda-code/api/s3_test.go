@@ -0,0 +1,99 @@
+package api
+
+import (
+	"context"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func testS3CoverStore(urlMode string) *S3CoverStore {
+	cfg := aws.Config{
+		Region: "ap-south-1",
+		Credentials: aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret"}, nil
+		}),
+	}
+	return &S3CoverStore{
+		client:       s3.NewFromConfig(cfg),
+		bucketName:   "movies-api-data",
+		region:       "ap-south-1",
+		prefix:       "images",
+		uploadPrefix: "uploads",
+		urlMode:      urlMode,
+		baseUrl:      "https://cdn.test",
+		urlTTL:       15 * time.Minute,
+	}
+}
+
+func TestS3CoverStoreObjectUrl(t *testing.T) {
+	tests := []struct {
+		urlMode string
+		want    string
+	}{
+		{"s3", "https://movies-api-data.s3.ap-south-1.amazonaws.com/images/m1.jpg"},
+		{"cdn", "https://cdn.test/images/m1.jpg"},
+	}
+	for _, tt := range tests {
+		got, err := testS3CoverStore(tt.urlMode).ObjectUrl(context.Background(), "m1.jpg")
+		if err != nil || got != tt.want {
+			t.Errorf("%v: ObjectUrl = %q, %v, want %q", tt.urlMode, got, err, tt.want)
+		}
+	}
+}
+
+func TestS3CoverStorePresignedObjectUrl(t *testing.T) {
+	store := testS3CoverStore("presigned")
+	ctx := withCoverUrlCache(context.Background())
+
+	first, err := store.ObjectUrl(ctx, "m1.jpg")
+	if err != nil {
+		t.Fatalf("ObjectUrl returned an error: %v", err)
+	}
+	parsed, err := url.Parse(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Path != "/images/m1.jpg" || parsed.Query().Get("X-Amz-Expires") != "900" || parsed.Query().Get("X-Amz-Signature") == "" {
+		t.Errorf("ObjectUrl = %q, want a GET of images/m1.jpg presigned for 900 seconds", first)
+	}
+
+	store.urlTTL = time.Hour
+	if cached, _ := store.ObjectUrl(ctx, "m1.jpg"); cached != first {
+		t.Errorf("ObjectUrl = %q, want the URL cached for the request %q", cached, first)
+	}
+	if uncached, _ := store.ObjectUrl(context.Background(), "m1.jpg"); uncached == first {
+		t.Error("ObjectUrl reused a URL without a request cache")
+	}
+}
+
+func TestS3CoverStorePresignUpload(t *testing.T) {
+	upload, err := testS3CoverStore("s3").PresignUpload(context.Background(), "m1/u1", "image/png", 1024, 10*time.Minute)
+	if err != nil {
+		t.Fatalf("PresignUpload returned an error: %v", err)
+	}
+	parsed, err := url.Parse(upload.Url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if upload.Method != "PUT" || parsed.Path != "/uploads/m1/u1" {
+		t.Errorf("upload = %v %v, want a PUT of uploads/m1/u1", upload.Method, upload.Url)
+	}
+
+	// S3 only rejects a body of another type or size if the headers are
+	// signed, so a client cannot upload more than was asked for.
+	signed := strings.Split(parsed.Query().Get("X-Amz-SignedHeaders"), ";")
+	for _, header := range []string{"content-length", "content-type"} {
+		if !slices.Contains(signed, header) {
+			t.Errorf("X-Amz-SignedHeaders = %v, want %v signed", signed, header)
+		}
+	}
+	if upload.Headers["Content-Type"] != "image/png" || upload.Headers["Content-Length"] != "1024" {
+		t.Errorf("headers = %v, want the signed Content-Type and Content-Length", upload.Headers)
+	}
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Field weights used to rank search results. A query word matching a whole
+// word counts double compared to matching only its prefix.
+const (
+	titleWeight   = 3
+	genreWeight   = 2
+	summaryWeight = 1
+
+	minTokenLength = 2
+	maxTokenLength = 20
+	maxSearchTerms = 10
+)
+
+// SearchResult is a movie matching a search together with its relevance.
+type SearchResult struct {
+	Movie
+	Score int `json:"score"`
+}
+
+// searchTokens returns every token a search can match movie by, mapped to
+// its weight. Tokens are the lower-cased words of the title, genre and
+// summary. Title and genre words are also indexed by all of their prefixes of
+// at least minTokenLength letters, summary words only whole, so that a long
+// summary does not put hundreds of entries in the index.
+func searchTokens(movie Movie) map[string]int {
+	tokens := map[string]int{}
+	add := func(text string, weight int, prefixes bool) {
+		for _, word := range searchWords(text) {
+			runes := []rune(word)
+			from := minTokenLength
+			if !prefixes {
+				from = min(len(runes), maxTokenLength)
+			}
+			for n := from; n <= len(runes) && n <= maxTokenLength; n++ {
+				tokenWeight := weight
+				if n == len(runes) {
+					tokenWeight = 2 * weight
+				}
+				token := string(runes[:n])
+				tokens[token] = max(tokens[token], tokenWeight)
+			}
+		}
+	}
+
+	add(movie.Title, titleWeight, true)
+	add(strings.Join(movie.Genre, " "), genreWeight, true)
+	add(movie.GeneratedSummary, summaryWeight, false)
+	return tokens
+}
+
+// searchWords splits text into lower-cased words of letters and digits.
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// parseSearchQuery turns the q parameter into the tokens to look up. Every
+// term has to match for a movie to be returned.
+func parseSearchQuery(query string) ([]string, error) {
+	var terms []string
+	for _, word := range searchWords(query) {
+		runes := []rune(word)
+		if len(runes) < minTokenLength {
+			continue
+		}
+		if len(runes) > maxTokenLength {
+			word = string(runes[:maxTokenLength])
+		}
+		if !slices.Contains(terms, word) {
+			terms = append(terms, word)
+		}
+	}
+
+	if len(terms) == 0 {
+		return nil, badRequest("q must contain a word of at least %d characters", minTokenLength)
+	}
+	if len(terms) > maxSearchTerms {
+		return nil, badRequest("q cannot contain more than %d words", maxSearchTerms)
+	}
+	return terms, nil
+}
+
+// scoreMatches combines the movieId to weight matches of each term into the
+// score of the movies that matched every term.
+func scoreMatches(termMatches []map[string]int) map[string]int {
+	if len(termMatches) == 0 {
+		return nil
+	}
+
+	scores := map[string]int{}
+	for movieId, weight := range termMatches[0] {
+		scores[movieId] = weight
+	}
+	for _, matches := range termMatches[1:] {
+		for movieId, score := range scores {
+			weight, ok := matches[movieId]
+			if !ok {
+				delete(scores, movieId)
+				continue
+			}
+			scores[movieId] = score + weight
+		}
+	}
+	return scores
+}
+
+// sortSearchResults orders results by descending score, then by title.
+func sortSearchResults(results []SearchResult) {
+	slices.SortFunc(results, func(a, b SearchResult) int {
+		if a.Score != b.Score {
+			return cmp.Compare(b.Score, a.Score)
+		}
+		return cmp.Compare(normalizeTitle(a.Title), normalizeTitle(b.Title))
+	})
+}
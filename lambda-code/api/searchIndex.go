@@ -0,0 +1,378 @@
+package api
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// The search table is an inverted index with one item per token and movie,
+// keyed by token (hash) and movieId (range) and carrying the token's weight.
+// A term lookup is a single Query on the token.
+const (
+	tokenAttr  = "token"
+	weightAttr = "weight"
+
+	// batchWriteSize is the most items BatchWriteItem accepts per call.
+	batchWriteSize = 25
+	// batchGetSize is the most keys BatchGetItem accepts per call.
+	batchGetSize = 100
+	// maxBatchAttempts bounds the retries of unprocessed batch items.
+	maxBatchAttempts = 5
+)
+
+func (s *DynamoStore) SearchMovies(ctx context.Context, terms []string, limit int32) ([]SearchResult, error) {
+	termMatches := make([]map[string]int, 0, len(terms))
+	for _, term := range terms {
+		matches, err := s.searchToken(ctx, term)
+		if err != nil {
+			return nil, err
+		}
+		termMatches = append(termMatches, matches)
+	}
+
+	scores := scoreMatches(termMatches)
+
+	// Only the best matches are fetched from the movies table, best first.
+	movieIds := make([]string, 0, len(scores))
+	for movieId := range scores {
+		movieIds = append(movieIds, movieId)
+	}
+	slices.SortFunc(movieIds, func(a, b string) int {
+		if scores[a] != scores[b] {
+			return cmp.Compare(scores[b], scores[a])
+		}
+		return cmp.Compare(a, b)
+	})
+
+	// Ids the index lags behind a delete for are not found and skipped, the
+	// next best matches are fetched in their place until limit is reached.
+	results := make([]SearchResult, 0, min(len(movieIds), int(limit)))
+	for len(movieIds) > 0 && len(results) < int(limit) {
+		batch := movieIds[:min(len(movieIds), int(limit)-len(results))]
+		movieIds = movieIds[len(batch):]
+
+		movies, err := s.GetMoviesByIds(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+		for _, movie := range movies {
+			results = append(results, SearchResult{Movie: movie, Score: scores[movie.MovieId]})
+		}
+	}
+
+	sortSearchResults(results)
+	return results, nil
+}
+
+// searchToken returns the weight of token for every movie indexed under it.
+func (s *DynamoStore) searchToken(ctx context.Context, token string) (map[string]int, error) {
+	keyCondition := expression.Key(tokenAttr).Equal(expression.Value(token))
+	projection := expression.NamesList(expression.Name("movieId"), expression.Name(weightAttr))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCondition).WithProjection(projection).Build()
+	if err != nil {
+		return nil, err
+	}
+
+	paginator := dynamodb.NewQueryPaginator(s.client, &dynamodb.QueryInput{
+		TableName:                 aws.String(s.searchTableName),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ProjectionExpression:      expr.Projection(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+
+	matches := map[string]int{}
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, upstreamError("DynamoDB", err)
+		}
+
+		var entries []struct {
+			MovieId string `dynamodbav:"movieId"`
+			Weight  int    `dynamodbav:"weight"`
+		}
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &entries); err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			matches[entry.MovieId] = entry.Weight
+		}
+	}
+	return matches, nil
+}
+
+// IndexStream applies a batch of records from the movies table's stream to
+// the search index, moving the entries of each movie from its old image to
+// its new one. The index is kept up to date this way rather than by the API,
+// so writes do not wait for the dozens of entries a movie has.
+//
+// Records are applied in order and the first that fails is reported with the
+// rest of the batch, which Lambda retries from that record. Replaying a
+// record that was already applied writes the same entries again.
+func (s *DynamoStore) IndexStream(ctx context.Context, event events.DynamoDBEvent) (events.DynamoDBEventResponse, error) {
+	var response events.DynamoDBEventResponse
+	for _, record := range event.Records {
+		if err := s.indexRecord(ctx, record); err != nil {
+			loggerFrom(ctx).Error("unable to update search index", "eventId", record.EventID, "error", err)
+			response.BatchItemFailures = []events.DynamoDBBatchItemFailure{{ItemIdentifier: record.Change.SequenceNumber}}
+			break
+		}
+	}
+	return response, nil
+}
+
+func (s *DynamoStore) indexRecord(ctx context.Context, record events.DynamoDBEventRecord) error {
+	var before, after Movie
+	if err := unmarshalStreamImage(record.Change.OldImage, &before); err != nil {
+		return err
+	}
+	if err := unmarshalStreamImage(record.Change.NewImage, &after); err != nil {
+		return err
+	}
+	return s.writeIndex(ctx, before, after)
+}
+
+// unmarshalStreamImage decodes an item image of a stream record into out. An
+// image missing from the record, the old one of an insert or the new one of
+// a remove, leaves out as is.
+func unmarshalStreamImage(image map[string]events.DynamoDBAttributeValue, out any) error {
+	if len(image) == 0 {
+		return nil
+	}
+	item := make(map[string]types.AttributeValue, len(image))
+	for name, value := range image {
+		item[name] = streamAttributeValue(value)
+	}
+	return attributevalue.UnmarshalMap(item, out)
+}
+
+// streamAttributeValue converts an attribute of the Lambda event into the
+// SDK's representation.
+func streamAttributeValue(value events.DynamoDBAttributeValue) types.AttributeValue {
+	switch value.DataType() {
+	case events.DataTypeBinary:
+		return &types.AttributeValueMemberB{Value: value.Binary()}
+	case events.DataTypeBinarySet:
+		return &types.AttributeValueMemberBS{Value: value.BinarySet()}
+	case events.DataTypeBoolean:
+		return &types.AttributeValueMemberBOOL{Value: value.Boolean()}
+	case events.DataTypeList:
+		list := make([]types.AttributeValue, len(value.List()))
+		for i, element := range value.List() {
+			list[i] = streamAttributeValue(element)
+		}
+		return &types.AttributeValueMemberL{Value: list}
+	case events.DataTypeMap:
+		members := make(map[string]types.AttributeValue, len(value.Map()))
+		for name, member := range value.Map() {
+			members[name] = streamAttributeValue(member)
+		}
+		return &types.AttributeValueMemberM{Value: members}
+	case events.DataTypeNumber:
+		return &types.AttributeValueMemberN{Value: value.Number()}
+	case events.DataTypeNumberSet:
+		return &types.AttributeValueMemberNS{Value: value.NumberSet()}
+	case events.DataTypeString:
+		return &types.AttributeValueMemberS{Value: value.String()}
+	case events.DataTypeStringSet:
+		return &types.AttributeValueMemberSS{Value: value.StringSet()}
+	default:
+		return &types.AttributeValueMemberNULL{Value: true}
+	}
+}
+
+func (s *DynamoStore) writeIndex(ctx context.Context, before Movie, after Movie) error {
+	movieId := cmp.Or(after.MovieId, before.MovieId)
+	oldTokens, newTokens := searchTokens(before), searchTokens(after)
+
+	var requests []types.WriteRequest
+	for token := range oldTokens {
+		if _, ok := newTokens[token]; !ok {
+			requests = append(requests, types.WriteRequest{DeleteRequest: &types.DeleteRequest{
+				Key: map[string]types.AttributeValue{
+					tokenAttr: &types.AttributeValueMemberS{Value: token},
+					"movieId": &types.AttributeValueMemberS{Value: movieId},
+				},
+			}})
+		}
+	}
+	for token, weight := range newTokens {
+		if oldTokens[token] != weight {
+			requests = append(requests, types.WriteRequest{PutRequest: &types.PutRequest{
+				Item: map[string]types.AttributeValue{
+					tokenAttr:  &types.AttributeValueMemberS{Value: token},
+					"movieId":  &types.AttributeValueMemberS{Value: movieId},
+					weightAttr: &types.AttributeValueMemberN{Value: strconv.Itoa(weight)},
+				},
+			}})
+		}
+	}
+
+	return s.batchWrite(ctx, s.searchTableName, requests)
+}
+
+// batchWrite applies requests to table in batches, retrying unprocessed
+// items with exponential backoff.
+func (s *DynamoStore) batchWrite(ctx context.Context, table string, requests []types.WriteRequest) error {
+	for batch := range slices.Chunk(requests, batchWriteSize) {
+		pending := map[string][]types.WriteRequest{table: batch}
+
+		for attempt := 0; len(pending) > 0; attempt++ {
+			if attempt == maxBatchAttempts {
+				return upstreamError("DynamoDB", errors.New("batch write items left unprocessed"))
+			}
+			if attempt > 0 {
+				if err := sleep(ctx, backoff(attempt)); err != nil {
+					return err
+				}
+			}
+
+			result, err := s.client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{RequestItems: pending})
+			if err != nil {
+				return upstreamError("DynamoDB", err)
+			}
+			pending = result.UnprocessedItems
+		}
+	}
+	return nil
+}
+
+// SearchIndexRebuild reports what RebuildSearchIndex did.
+type SearchIndexRebuild struct {
+	Indexed int
+	Removed int
+}
+
+// RebuildSearchIndex indexes every movie in the table and then removes the
+// entries no movie yields any more, those of deleted movies and of words a
+// movie lost while its index update failed. It is run by cmd/reindex to
+// backfill movies written before the index existed or to repair stream
+// records whose index update failed for good.
+func (s *DynamoStore) RebuildSearchIndex(ctx context.Context) (SearchIndexRebuild, error) {
+	var rebuild SearchIndexRebuild
+
+	paginator := dynamodb.NewScanPaginator(s.client, &dynamodb.ScanInput{
+		TableName: aws.String(s.tableName),
+	})
+
+	indexed := map[string]map[string]int{}
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return rebuild, upstreamError("DynamoDB", err)
+		}
+
+		var movies []Movie
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &movies); err != nil {
+			return rebuild, err
+		}
+		for _, movie := range movies {
+			if err := s.writeIndex(ctx, Movie{}, movie); err != nil {
+				return rebuild, err
+			}
+			indexed[movie.MovieId] = searchTokens(movie)
+			rebuild.Indexed++
+		}
+	}
+
+	removed, err := s.removeStaleEntries(ctx, indexed)
+	rebuild.Removed = removed
+	return rebuild, err
+}
+
+// removeStaleEntries deletes the search entries that are not among the
+// tokens indexed for each movie. Movies can be written while the index is
+// rebuilt, so an entry is only deleted once the movie as it is now, if it
+// still exists, does not yield it either.
+func (s *DynamoStore) removeStaleEntries(ctx context.Context, indexed map[string]map[string]int) (int, error) {
+	projection := expression.NamesList(expression.Name(tokenAttr), expression.Name("movieId"))
+	expr, err := expression.NewBuilder().WithProjection(projection).Build()
+	if err != nil {
+		return 0, err
+	}
+
+	paginator := dynamodb.NewScanPaginator(s.client, &dynamodb.ScanInput{
+		TableName:                aws.String(s.searchTableName),
+		ProjectionExpression:     expr.Projection(),
+		ExpressionAttributeNames: expr.Names(),
+	})
+
+	stale := map[string][]string{}
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return 0, upstreamError("DynamoDB", err)
+		}
+
+		var entries []struct {
+			Token   string `dynamodbav:"token"`
+			MovieId string `dynamodbav:"movieId"`
+		}
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &entries); err != nil {
+			return 0, err
+		}
+		for _, entry := range entries {
+			if _, ok := indexed[entry.MovieId][entry.Token]; !ok {
+				stale[entry.MovieId] = append(stale[entry.MovieId], entry.Token)
+			}
+		}
+	}
+
+	current, err := s.GetMoviesByIds(ctx, slices.Collect(maps.Keys(stale)))
+	if err != nil {
+		return 0, err
+	}
+	currentTokens := make(map[string]map[string]int, len(current))
+	for _, movie := range current {
+		currentTokens[movie.MovieId] = searchTokens(movie)
+	}
+
+	var requests []types.WriteRequest
+	for movieId, tokens := range stale {
+		for _, token := range tokens {
+			if _, ok := currentTokens[movieId][token]; ok {
+				continue
+			}
+			requests = append(requests, types.WriteRequest{DeleteRequest: &types.DeleteRequest{
+				Key: map[string]types.AttributeValue{
+					tokenAttr: &types.AttributeValueMemberS{Value: token},
+					"movieId": &types.AttributeValueMemberS{Value: movieId},
+				},
+			}})
+		}
+	}
+	if err := s.batchWrite(ctx, s.searchTableName, requests); err != nil {
+		return 0, err
+	}
+	return len(requests), nil
+}
+
+// backoff returns the delay before retry attempt, doubling from 50ms.
+func backoff(attempt int) time.Duration {
+	return 50 * time.Millisecond << (attempt - 1)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
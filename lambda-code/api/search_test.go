@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestSearchTokens(t *testing.T) {
+	tokens := searchTokens(Movie{Title: "The Dark Knight", Genre: Genres{"Action", "Crime"}, GeneratedSummary: "Batman fights crime."})
+
+	want := map[string]int{
+		"th":     titleWeight,
+		"the":    2 * titleWeight,
+		"dark":   2 * titleWeight,
+		"kni":    titleWeight,
+		"knight": 2 * titleWeight,
+		"act":    genreWeight,
+		"crime":  2 * genreWeight, // the summary word weighs less than the genre
+		"batman": 2 * summaryWeight,
+		"fights": 2 * summaryWeight,
+	}
+	for token, weight := range want {
+		if tokens[token] != weight {
+			t.Errorf("tokens[%q] = %d, want %d", token, tokens[token], weight)
+		}
+	}
+	if _, ok := tokens["k"]; ok {
+		t.Error("single letter prefixes should not be indexed")
+	}
+	if _, ok := tokens["fig"]; ok {
+		t.Error("prefixes of summary words should not be indexed")
+	}
+}
+
+func TestParseSearchQuery(t *testing.T) {
+	terms, err := parseSearchQuery("  Dark, KNIGHT dark a ")
+	if err != nil {
+		t.Fatalf("parseSearchQuery: %v", err)
+	}
+	if want := []string{"dark", "knight"}; !slices.Equal(terms, want) {
+		t.Errorf("terms = %v, want %v", terms, want)
+	}
+
+	for _, query := range []string{"", "a", "!!", "a b c d e f g h i j k l m n o p q r s t u v w x y z aa bb cc dd ee ff gg hh ii jj kk"} {
+		if _, err := parseSearchQuery(query); !errors.Is(err, ErrBadRequest) {
+			t.Errorf("parseSearchQuery(%q) = %v, want a bad request", query, err)
+		}
+	}
+}
+
+func TestMemoryStoreSearchMovies(t *testing.T) {
+	store := NewMemoryStore(
+		Movie{MovieId: "1", Title: "Heat", Genre: Genres{"Crime", "Thriller"}},
+		Movie{MovieId: "2", Title: "The Departed", Genre: Genres{"Crime", "Drama"}},
+		Movie{MovieId: "3", Title: "Crimson Peak", Genre: Genres{"Horror"}},
+		Movie{MovieId: "4", Title: "Inception", Genre: Genres{"Science Fiction"}, GeneratedSummary: "A heist inside dreams, not a crime drama."},
+	)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		// Title matches rank above genre matches, whole words above prefixes
+		// and equal scores are ordered by title. Summaries only match whole
+		// words.
+		{"crim", []string{"3", "1", "2"}},
+		{"crime", []string{"1", "2", "4"}},
+		{"crime drama", []string{"2", "4"}},
+		{"HEAT", []string{"1"}},
+		{"westerns", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			terms, err := parseSearchQuery(tt.query)
+			if err != nil {
+				t.Fatalf("parseSearchQuery: %v", err)
+			}
+			results, err := store.SearchMovies(context.Background(), terms, 10)
+			if err != nil {
+				t.Fatalf("SearchMovies: %v", err)
+			}
+
+			var got []string
+			for _, result := range results {
+				got = append(got, result.MovieId)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"context"
+	"strings"
+)
+
+type Movie struct {
+	MovieId          string        `json:"movieId" dynamodbav:"movieId"`
+	Title            string        `json:"title" dynamodbav:"title"`
+	ReleaseYear      uint16        `json:"releaseYear" dynamodbav:"releaseYear"`
+	Genre            Genres        `json:"genre" dynamodbav:"genre"`
+	CoverUrl         string        `json:"coverUrl" dynamodbav:"-"`
+	Covers           CoverVariants `json:"covers,omitempty" dynamodbav:"-"`
+	GeneratedSummary string        `json:"generatedSummary,omitempty" dynamodbav:"generatedSummary,omitempty"`
+	// CoverKey is the object key of the cover in the CoverStore and
+	// CoverKeys those of its resized variants. Only the keys are stored,
+	// CoverUrl and Covers are rendered from them for each response by
+	// Handler.renderCover.
+	CoverKey  string        `json:"-" dynamodbav:"coverKey,omitempty"`
+	CoverKeys CoverVariants `json:"-" dynamodbav:"coverKeys,omitempty"`
+	// LegacyCoverUrl and LegacyCovers are the absolute URLs movies written
+	// before covers were stored by key hold instead of CoverKey and
+	// CoverKeys, until cmd/migrate-cover-keys has replaced them. They are
+	// read through movieCover and removed by any write to the cover.
+	LegacyCoverUrl string        `json:"-" dynamodbav:"coverUrl,omitempty"`
+	LegacyCovers   CoverVariants `json:"-" dynamodbav:"covers,omitempty"`
+	// Version is incremented on every write and exposed to clients as the
+	// ETag. Items written before versioning have no attribute and read as 0.
+	Version int64 `json:"-" dynamodbav:"version"`
+}
+
+// MovieStore is the persistence layer used by the handlers. DynamoStore is the
+// production implementation and MemoryStore keeps movies in process for tests
+// and local runs. Lookups of a missing movie return ErrMovieNotFound and
+// failures talking to the database are returned as an UpstreamError.
+type MovieStore interface {
+	// GetAllMovies returns a page of the movies matching filter, with the
+	// cursor of the next page. They are ordered by sort, see ListQuery.Sort,
+	// and otherwise in the store's own order, which is by release year when
+	// filter bounds it. The store bounds the work done per page, so a page
+	// can be short or empty and still have a cursor. A non-empty fields
+	// limits the attributes read to those named, leaving the others at their
+	// zero value; it has to include the attributes filter and sort depend on.
+	GetAllMovies(ctx context.Context, limit int32, cursor string, filter MovieFilter, sort string, fields []string) ([]Movie, string, error)
+	GetMovieById(ctx context.Context, movieId string) (Movie, error)
+	// GetMoviesByIds returns the movies among movieIds that exist, in no
+	// particular order. movieIds must not repeat an id.
+	GetMoviesByIds(ctx context.Context, movieIds []string) ([]Movie, error)
+	// CountGenres returns how many movies are tagged with each genre.
+	CountGenres(ctx context.Context) (map[string]int, error)
+	// SearchMovies returns up to limit movies whose title, genre or summary
+	// has a word starting with every one of terms, best matches first. terms
+	// come from parseSearchQuery.
+	SearchMovies(ctx context.Context, terms []string, limit int32) ([]SearchResult, error)
+	// AddMovie and UpdateMovieById return ErrTitleExists when another movie
+	// already uses the same title, compared with normalizeTitle.
+	AddMovie(ctx context.Context, movie Movie) error
+	// The writes below take the version the client expects the movie to be
+	// at and return ErrVersionMismatch when it has moved on. A nil
+	// expectedVersion writes unconditionally.
+	//
+	// UpdateMovieById replaces the client editable fields and returns the
+	// movie as it is after the update.
+	UpdateMovieById(ctx context.Context, movieId string, movie Movie, expectedVersion *int64) (Movie, error)
+	// PatchMovieById applies a partial update and returns the movie as it is
+	// after the update.
+	PatchMovieById(ctx context.Context, movieId string, patch MoviePatch, expectedVersion *int64) (Movie, error)
+	DeleteMovieById(ctx context.Context, movieId string, expectedVersion *int64) (Movie, error)
+	UpdateMovieSummary(ctx context.Context, movieId string, summary string) error
+}
+
+// normalizeTitle is the form titles are compared in for uniqueness: case is
+// folded and runs of whitespace collapse to a single space.
+func normalizeTitle(title string) string {
+	return strings.ToLower(strings.Join(strings.Fields(title), " "))
+}
+
+// MoviePatch is a partial update to a movie. Nil fields are left untouched and
+// the Remove flags delete the attribute from the item. CoverKeys is written
+// together with CoverKey and removed with it.
+type MoviePatch struct {
+	Title                  *string
+	ReleaseYear            *uint16
+	Genre                  *Genres
+	CoverKey               *string
+	CoverKeys              CoverVariants
+	GeneratedSummary       *string
+	RemoveCover            bool
+	RemoveGeneratedSummary bool
+}
+
+// replacePatch is the patch a PUT applies: title, releaseYear and genre are
+// always overwritten and the cover only when a new one was uploaded.
+func replacePatch(movie Movie) MoviePatch {
+	patch := MoviePatch{
+		Title:       &movie.Title,
+		ReleaseYear: &movie.ReleaseYear,
+		Genre:       &movie.Genre,
+	}
+	if movie.CoverKey != "" {
+		patch.CoverKey = &movie.CoverKey
+		patch.CoverKeys = movie.CoverKeys
+	}
+	return patch
+}
+
+func (p MoviePatch) isEmpty() bool {
+	return p.Title == nil && p.ReleaseYear == nil && p.Genre == nil && p.CoverKey == nil &&
+		p.GeneratedSummary == nil && !p.RemoveCover && !p.RemoveGeneratedSummary
+}
+
+// apply returns movie with the patch applied and its version bumped.
+func (p MoviePatch) apply(movie Movie) Movie {
+	movie.Version++
+
+	if p.Title != nil {
+		movie.Title = *p.Title
+	}
+	if p.ReleaseYear != nil {
+		movie.ReleaseYear = *p.ReleaseYear
+	}
+	if p.Genre != nil {
+		movie.Genre = *p.Genre
+	}
+	if p.CoverKey != nil {
+		movie.CoverKey = *p.CoverKey
+		movie.CoverKeys = p.CoverKeys
+		movie.LegacyCoverUrl, movie.LegacyCovers = "", nil
+	}
+	if p.GeneratedSummary != nil {
+		movie.GeneratedSummary = *p.GeneratedSummary
+	}
+	if p.RemoveCover {
+		movie.CoverKey = ""
+		movie.CoverKeys = nil
+		movie.LegacyCoverUrl, movie.LegacyCovers = "", nil
+	}
+	if p.RemoveGeneratedSummary {
+		movie.GeneratedSummary = ""
+	}
+	return movie
+}
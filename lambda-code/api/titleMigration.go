@@ -0,0 +1,136 @@
+package api
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// TitleMigration reports what MigrateTitles did. Duplicates lists the
+// movieIds per normalized title that more than one movie uses, or that is
+// held by another movie; only the oldest of them is given the title record,
+// the others have to be renamed or deleted by hand.
+type TitleMigration struct {
+	Scanned    int
+	Claimed    int
+	Duplicates map[string][]string
+}
+
+// MigrateTitles writes the MovieTitles record of every movie written before
+// the titles table existed. Records a movie already holds are rewritten
+// unchanged, so it is safe to run more than once. With dryRun the table is
+// only read and the duplicates reported. It is run by cmd/migrate-titles.
+func (s *DynamoStore) MigrateTitles(ctx context.Context, dryRun bool) (TitleMigration, error) {
+	migration := TitleMigration{Duplicates: map[string][]string{}}
+
+	projection := expression.NamesList(expression.Name("movieId"), expression.Name("title"))
+	expr, err := expression.NewBuilder().WithProjection(projection).Build()
+	if err != nil {
+		return migration, err
+	}
+
+	paginator := dynamodb.NewScanPaginator(s.client, &dynamodb.ScanInput{
+		TableName:                aws.String(s.tableName),
+		ProjectionExpression:     expr.Projection(),
+		ExpressionAttributeNames: expr.Names(),
+	})
+
+	owners := map[string][]Movie{}
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return migration, upstreamError("DynamoDB", err)
+		}
+
+		var movies []Movie
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &movies); err != nil {
+			return migration, err
+		}
+		for _, movie := range movies {
+			migration.Scanned++
+			title := normalizeTitle(movie.Title)
+			owners[title] = append(owners[title], movie)
+		}
+	}
+
+	for title, movies := range owners {
+		// movieIds are UUIDv7, so the smallest is the oldest movie.
+		slices.SortFunc(movies, func(a, b Movie) int { return cmp.Compare(a.MovieId, b.MovieId) })
+		if len(movies) > 1 {
+			for _, movie := range movies {
+				migration.Duplicates[title] = append(migration.Duplicates[title], movie.MovieId)
+			}
+		}
+
+		if dryRun {
+			continue
+		}
+		claimed, err := s.claimTitle(ctx, movies[0])
+		if errors.Is(err, ErrTitleExists) {
+			if len(movies) == 1 {
+				migration.Duplicates[title] = []string{movies[0].MovieId}
+			}
+			continue
+		}
+		if err != nil {
+			return migration, err
+		}
+		if claimed {
+			migration.Claimed++
+		}
+	}
+	return migration, nil
+}
+
+// claimTitle writes the title record of movie, provided the movie still has
+// the title it was scanned with. claimed is false when it has been renamed
+// or deleted since, in which case that write claimed its new title, and
+// ErrTitleExists is returned when another movie holds the record.
+func (s *DynamoStore) claimTitle(ctx context.Context, movie Movie) (claimed bool, err error) {
+	_, err = s.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{ConditionCheck: &types.ConditionCheck{
+				TableName: aws.String(s.tableName),
+				Key: map[string]types.AttributeValue{
+					"movieId": &types.AttributeValueMemberS{Value: movie.MovieId},
+				},
+				ConditionExpression: aws.String("title = :title"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":title": &types.AttributeValueMemberS{Value: movie.Title},
+				},
+			}},
+			{Put: &types.Put{
+				TableName: aws.String(s.titlesTableName),
+				Item: map[string]types.AttributeValue{
+					normalizedTitleAttr: &types.AttributeValueMemberS{Value: normalizeTitle(movie.Title)},
+					"movieId":           &types.AttributeValueMemberS{Value: movie.MovieId},
+				},
+				ConditionExpression: aws.String("attribute_not_exists(#title) OR movieId = :movieId"),
+				ExpressionAttributeNames: map[string]string{
+					"#title": normalizedTitleAttr,
+				},
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":movieId": &types.AttributeValueMemberS{Value: movie.MovieId},
+				},
+			}},
+		},
+	})
+
+	if err != nil {
+		switch {
+		case conditionFailed(err, 0):
+			return false, nil
+		case conditionFailed(err, 1):
+			return false, ErrTitleExists
+		}
+		return false, upstreamError("DynamoDB", err)
+	}
+	return true, nil
+}
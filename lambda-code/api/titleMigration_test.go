@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestMigrateTitles(t *testing.T) {
+	// A copy of Heat added before titles were unique.
+	heatCopy := Movie{MovieId: "0190a2f0-0000-7000-8000-000000000009", Title: " HEAT"}
+
+	t.Run("claims the oldest movie of each title", func(t *testing.T) {
+		store, fake := newFakeDynamoStore(t)
+		fake.scans[testTable] = []*dynamodb.ScanOutput{{Items: fake.items(heatCopy, heat, inception)}}
+
+		migration, err := store.MigrateTitles(context.Background(), false)
+		if err != nil {
+			t.Fatalf("MigrateTitles: %v", err)
+		}
+		if migration.Scanned != 3 || migration.Claimed != 2 {
+			t.Errorf("migration = %+v, want 3 scanned and 2 claimed", migration)
+		}
+		if want := []string{heat.MovieId, heatCopy.MovieId}; len(migration.Duplicates) != 1 || !slices.Equal(migration.Duplicates["heat"], want) {
+			t.Errorf("duplicates = %v, want heat shared by %v", migration.Duplicates, want)
+		}
+
+		var claimed []string
+		for _, input := range fake.transactInputs {
+			owner := input.TransactItems[1].Put.Item["movieId"].(*types.AttributeValueMemberS)
+			claimed = append(claimed, owner.Value)
+		}
+		slices.Sort(claimed)
+		if want := []string{heat.MovieId, inception.MovieId}; !slices.Equal(claimed, want) {
+			t.Errorf("claimed for %v, want %v", claimed, want)
+		}
+	})
+
+	t.Run("reports a title held by another movie", func(t *testing.T) {
+		store, fake := newFakeDynamoStore(t)
+		fake.scans[testTable] = []*dynamodb.ScanOutput{{Items: fake.items(inception)}}
+		fake.transactErr = transactionCanceled("None", "ConditionalCheckFailed")
+
+		migration, err := store.MigrateTitles(context.Background(), false)
+		if err != nil {
+			t.Fatalf("MigrateTitles: %v", err)
+		}
+		if migration.Claimed != 0 || !slices.Equal(migration.Duplicates["inception"], []string{inception.MovieId}) {
+			t.Errorf("migration = %+v, want inception reported", migration)
+		}
+	})
+
+	t.Run("skips a movie renamed since the scan", func(t *testing.T) {
+		store, fake := newFakeDynamoStore(t)
+		fake.scans[testTable] = []*dynamodb.ScanOutput{{Items: fake.items(inception)}}
+		fake.transactErr = transactionCanceled("ConditionalCheckFailed", "None")
+
+		migration, err := store.MigrateTitles(context.Background(), false)
+		if err != nil {
+			t.Fatalf("MigrateTitles: %v", err)
+		}
+		if migration.Claimed != 0 || len(migration.Duplicates) != 0 {
+			t.Errorf("migration = %+v, want nothing claimed or reported", migration)
+		}
+	})
+
+	t.Run("dry run only reports", func(t *testing.T) {
+		store, fake := newFakeDynamoStore(t)
+		fake.scans[testTable] = []*dynamodb.ScanOutput{{Items: fake.items(heatCopy, heat)}}
+
+		migration, err := store.MigrateTitles(context.Background(), true)
+		if err != nil {
+			t.Fatalf("MigrateTitles: %v", err)
+		}
+		if len(fake.transactInputs) != 0 || len(migration.Duplicates["heat"]) != 2 {
+			t.Errorf("migration = %+v after %d writes, want heat reported and nothing written", migration, len(fake.transactInputs))
+		}
+	})
+}
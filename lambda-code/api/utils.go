@@ -0,0 +1,140 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+)
+
+type Response struct {
+	Status     bool   `json:"status"`
+	Data       any    `json:"data"`
+	Message    string `json:"message"`
+	StatusCode int    `json:"statusCode"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+func response(statusCode int, status bool, message string, data any) events.APIGatewayProxyResponse {
+	return buildResponse(Response{
+		Status:     status,
+		StatusCode: statusCode,
+		Message:    message,
+		Data:       data,
+	})
+}
+
+// paginatedResponse is response with a cursor pointing at the next page.
+// An empty nextCursor means the client has reached the last page.
+func paginatedResponse(statusCode int, status bool, message string, data any, nextCursor string) events.APIGatewayProxyResponse {
+	return buildResponse(Response{
+		Status:     status,
+		StatusCode: statusCode,
+		Message:    message,
+		Data:       data,
+		NextCursor: nextCursor,
+	})
+}
+
+func buildResponse(res Response) events.APIGatewayProxyResponse {
+	jsonRes, err := json.Marshal(res)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       err.Error(),
+		}
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: res.StatusCode,
+		Body:       string(jsonRes),
+	}
+}
+
+func getHeaders(headers map[string]string, key string) string {
+	for k, v := range headers {
+		if strings.ToLower(k) == strings.ToLower(key) {
+			return v
+		}
+	}
+	return ""
+}
+
+func generateUUID() (string, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return "", err
+	}
+
+	return id.String(), err
+}
+
+// parseLimit reads the page size from the limit query parameter, falling back
+// to defaultPageSize when it is not provided.
+func parseLimit(limit string) (int32, error) {
+	if limit == "" {
+		return defaultPageSize, nil
+	}
+
+	limitInt, err := strconv.Atoi(limit)
+	if err != nil || limitInt < 1 || limitInt > maxPageSize {
+		return 0, badRequest("limit must be a number between 1 and %d", maxPageSize)
+	}
+	return int32(limitInt), nil
+}
+
+// parseGenreFilter validates the genre query parameter, returning its
+// vocabulary spelling or "" when it is not set.
+func parseGenreFilter(genre string) (string, error) {
+	if genre == "" {
+		return "", nil
+	}
+
+	canonical, ok := canonicalGenre(genre)
+	if !ok {
+		return "", badRequest("genre must be one of: %v", strings.Join(genreVocabulary, ", "))
+	}
+	return canonical, nil
+}
+
+// parseYear converts a year query parameter into a number. An empty string is
+// returned as 0, which the store treats as an open bound.
+func parseYear(year string) (uint16, error) {
+	if year == "" {
+		return 0, nil
+	}
+
+	yearInt, err := strconv.Atoi(year)
+	if err != nil || yearInt < 1 || yearInt > 9999 {
+		return 0, badRequest("invalid year '%v'", year)
+	}
+	return uint16(yearInt), nil
+}
+
+// formatETag renders a movie version as a strong entity tag.
+func formatETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// expectedVersion checks an If-Match header against the movie's current
+// version. It returns the version the write must be conditioned on, nil when
+// the client sent no precondition, or ErrVersionMismatch when none of the
+// listed entity tags match. Weak tags never match as If-Match requires strong
+// comparison.
+func expectedVersion(ifMatch string, movie Movie) (*int64, error) {
+	ifMatch = strings.TrimSpace(ifMatch)
+	if ifMatch == "" || ifMatch == "*" {
+		return nil, nil
+	}
+
+	current := formatETag(movie.Version)
+	for _, etag := range strings.Split(ifMatch, ",") {
+		if strings.TrimSpace(etag) == current {
+			return &movie.Version, nil
+		}
+	}
+	return nil, ErrVersionMismatch
+}
@@ -0,0 +1,33 @@
+// Command indexer is the Lambda that keeps the search index up to date. It
+// consumes the stream of the movies table, so the API does not write the
+// index itself. Build it like the API Lambda:
+//
+//	GOOS=linux GOARCH=amd64 go build -tags lambda.norpc -o cmd/indexer/bootstrap ./cmd/indexer
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+
+	"github.com/AJ-Walker/movies-rest-api-lambda/api"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+func main() {
+	ctx := context.Background()
+
+	config, err := api.LoadConfig()
+	if err != nil {
+		slog.Error("unable to load configuration", "error", err)
+		os.Exit(1)
+	}
+	slog.SetDefault(api.NewLogger(config.LogLevel))
+
+	awsConfig, err := api.LoadAWSConfig(ctx, config)
+	if err != nil {
+		slog.Error("unable to load AWS configuration", "error", err)
+		os.Exit(1)
+	}
+	lambda.Start(api.NewDynamoStore(awsConfig, config).IndexStream)
+}
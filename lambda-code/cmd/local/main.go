@@ -0,0 +1,40 @@
+// Command local serves the movies API over plain HTTP so it can be exercised
+// without deploying to Lambda. It uses the same configuration as the Lambda;
+// set MOVIE_STORE=memory to keep movies in memory instead of DynamoDB.
+//
+//	MOVIE_STORE=memory go run ./cmd/local -addr :8080
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"github.com/AJ-Walker/movies-rest-api-lambda/api"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "address to listen on")
+	flag.Parse()
+
+	config, err := api.LoadConfig()
+	if err != nil {
+		slog.Error("unable to load configuration", "error", err)
+		os.Exit(1)
+	}
+	slog.SetDefault(api.NewLogger(config.LogLevel))
+
+	handler, err := api.NewHandlerFromConfig(context.Background(), config)
+	if err != nil {
+		slog.Error("unable to initialise handler", "error", err)
+		os.Exit(1)
+	}
+
+	slog.Info("serving movies API", "addr", *addr, "movieStore", config.MovieStore)
+	if err := http.ListenAndServe(*addr, &proxy{handler: handler.HandleRequest, maxBodyBytes: config.MaxUploadBytes}); err != nil {
+		slog.Error("server stopped", "error", err)
+		os.Exit(1)
+	}
+}
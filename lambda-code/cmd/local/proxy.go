@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+)
+
+// binaryMediaTypes mirrors the binary_media_types of the API Gateway in
+// aws-infra. Bodies of these types reach the Lambda base64 encoded.
+var binaryMediaTypes = map[string]bool{
+	"multipart/form-data": true,
+}
+
+// proxy translates HTTP requests into the API Gateway proxy events the
+// Lambda receives, and the proxy responses back into HTTP responses.
+type proxy struct {
+	handler      func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+	maxBodyBytes int64
+}
+
+func (p *proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Leave room for the multipart framing, the handler enforces the real
+	// limit on the decoded body.
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 2*p.maxBodyBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	event := proxyRequest(r, body)
+
+	res, err := p.handler(r.Context(), event)
+	if err != nil {
+		slog.Error("handler failed", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeProxyResponse(w, res)
+}
+
+// proxyRequest builds the event API Gateway would send for r.
+func proxyRequest(r *http.Request, body []byte) events.APIGatewayProxyRequest {
+	event := events.APIGatewayProxyRequest{
+		Resource:   "/{proxy+}",
+		Path:       r.URL.Path,
+		HTTPMethod: r.Method,
+		Headers:    map[string]string{},
+		RequestContext: events.APIGatewayProxyRequestContext{
+			RequestID:  uuid.NewString(),
+			HTTPMethod: r.Method,
+			Path:       r.URL.Path,
+			Stage:      "local",
+		},
+		MultiValueHeaders: map[string][]string{},
+	}
+
+	for name, values := range r.Header {
+		event.Headers[name] = values[len(values)-1]
+		event.MultiValueHeaders[name] = values
+	}
+	if r.Host != "" {
+		event.Headers["Host"] = r.Host
+	}
+
+	if query := r.URL.Query(); len(query) > 0 {
+		event.QueryStringParameters = map[string]string{}
+		event.MultiValueQueryStringParameters = map[string][]string{}
+		for name, values := range query {
+			event.QueryStringParameters[name] = values[len(values)-1]
+			event.MultiValueQueryStringParameters[name] = values
+		}
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if binaryMediaTypes[mediaType] {
+		event.Body = base64.StdEncoding.EncodeToString(body)
+		event.IsBase64Encoded = true
+	} else {
+		event.Body = string(body)
+	}
+
+	return event
+}
+
+func writeProxyResponse(w http.ResponseWriter, res events.APIGatewayProxyResponse) {
+	for name, value := range res.Headers {
+		w.Header().Set(name, value)
+	}
+	for name, values := range res.MultiValueHeaders {
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+
+	body := []byte(res.Body)
+	if res.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(res.Body)
+		if err != nil {
+			slog.Error("unable to decode response body", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		body = decoded
+	}
+
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+
+	statusCode := res.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	w.WriteHeader(statusCode)
+	w.Write(body)
+}
@@ -0,0 +1,44 @@
+// Command migrate-cover-keys replaces the absolute cover URLs stored on
+// movies written before covers were stored by object key with the keys. The
+// API renders those covers from the URLs until then, so it can be run any
+// time after deploying the change; -dry-run reports what would change
+// without writing. It uses the same configuration as the Lambda.
+//
+//	go run ./cmd/migrate-cover-keys -dry-run
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"os"
+
+	"github.com/AJ-Walker/movies-rest-api-lambda/api"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "report the movies to migrate without writing")
+	flag.Parse()
+
+	ctx := context.Background()
+
+	config, err := api.LoadConfig()
+	if err != nil {
+		slog.Error("unable to load configuration", "error", err)
+		os.Exit(1)
+	}
+	slog.SetDefault(api.NewLogger(config.LogLevel))
+
+	awsConfig, err := api.LoadAWSConfig(ctx, config)
+	if err != nil {
+		slog.Error("unable to load AWS configuration", "error", err)
+		os.Exit(1)
+	}
+
+	migration, err := api.NewDynamoStore(awsConfig, config).MigrateCoverKeys(ctx, *dryRun)
+	if err != nil {
+		slog.Error("unable to migrate cover keys", "migrated", migration.Migrated, "error", err)
+		os.Exit(1)
+	}
+	slog.Info("cover keys migrated", "table", config.TableName, "dryRun", *dryRun, "movies", migration.Scanned, "migrated", migration.Migrated)
+}
@@ -0,0 +1,43 @@
+// Command migrate-entity-type sets entityType=movie on movies written before
+// the releaseYear index existed, which year filters miss until then. Run it
+// once after creating the index; -dry-run reports what would change without
+// writing. It uses the same configuration as the Lambda.
+//
+//	go run ./cmd/migrate-entity-type -dry-run
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"os"
+
+	"github.com/AJ-Walker/movies-rest-api-lambda/api"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "report the movies to migrate without writing")
+	flag.Parse()
+
+	ctx := context.Background()
+
+	config, err := api.LoadConfig()
+	if err != nil {
+		slog.Error("unable to load configuration", "error", err)
+		os.Exit(1)
+	}
+	slog.SetDefault(api.NewLogger(config.LogLevel))
+
+	awsConfig, err := api.LoadAWSConfig(ctx, config)
+	if err != nil {
+		slog.Error("unable to load AWS configuration", "error", err)
+		os.Exit(1)
+	}
+
+	migration, err := api.NewDynamoStore(awsConfig, config).MigrateEntityType(ctx, *dryRun)
+	if err != nil {
+		slog.Error("unable to migrate entity type", "migrated", migration.Migrated, "error", err)
+		os.Exit(1)
+	}
+	slog.Info("entity type migrated", "table", config.TableName, "dryRun", *dryRun, "movies", migration.Scanned, "migrated", migration.Migrated)
+}
@@ -0,0 +1,46 @@
+// Command migrate-genres converts the comma separated genre strings of
+// movies written before genres were a list into string sets. Run it once
+// after deploying the genre change; -dry-run reports what would change
+// without writing. It uses the same configuration as the Lambda.
+//
+//	go run ./cmd/migrate-genres -dry-run
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"os"
+
+	"github.com/AJ-Walker/movies-rest-api-lambda/api"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "report the movies to migrate without writing")
+	flag.Parse()
+
+	ctx := context.Background()
+
+	config, err := api.LoadConfig()
+	if err != nil {
+		slog.Error("unable to load configuration", "error", err)
+		os.Exit(1)
+	}
+	slog.SetDefault(api.NewLogger(config.LogLevel))
+
+	awsConfig, err := api.LoadAWSConfig(ctx, config)
+	if err != nil {
+		slog.Error("unable to load AWS configuration", "error", err)
+		os.Exit(1)
+	}
+
+	migration, err := api.NewDynamoStore(awsConfig, config).MigrateGenres(ctx, *dryRun)
+	if err != nil {
+		slog.Error("unable to migrate genres", "migrated", migration.Migrated, "error", err)
+		os.Exit(1)
+	}
+	for movieId, genres := range migration.Unknown {
+		slog.Warn("movie has genres outside the vocabulary", "movieId", movieId, "genres", genres)
+	}
+	slog.Info("genres migrated", "table", config.TableName, "dryRun", *dryRun, "movies", migration.Scanned, "migrated", migration.Migrated)
+}
@@ -0,0 +1,47 @@
+// Command migrate-titles writes the MovieTitles record of movies written
+// before title uniqueness was enforced and reports the titles more than one
+// of them already shares. Run it once after creating the titles table;
+// -dry-run only reports the duplicates. It uses the same configuration as
+// the Lambda.
+//
+//	go run ./cmd/migrate-titles -dry-run
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"os"
+
+	"github.com/AJ-Walker/movies-rest-api-lambda/api"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "report the duplicate titles without writing")
+	flag.Parse()
+
+	ctx := context.Background()
+
+	config, err := api.LoadConfig()
+	if err != nil {
+		slog.Error("unable to load configuration", "error", err)
+		os.Exit(1)
+	}
+	slog.SetDefault(api.NewLogger(config.LogLevel))
+
+	awsConfig, err := api.LoadAWSConfig(ctx, config)
+	if err != nil {
+		slog.Error("unable to load AWS configuration", "error", err)
+		os.Exit(1)
+	}
+
+	migration, err := api.NewDynamoStore(awsConfig, config).MigrateTitles(ctx, *dryRun)
+	if err != nil {
+		slog.Error("unable to migrate titles", "claimed", migration.Claimed, "error", err)
+		os.Exit(1)
+	}
+	for title, movieIds := range migration.Duplicates {
+		slog.Warn("title is used by more than one movie", "title", title, "movieIds", movieIds)
+	}
+	slog.Info("titles migrated", "table", config.TitlesTableName, "dryRun", *dryRun, "movies", migration.Scanned, "claimed", migration.Claimed, "duplicates", len(migration.Duplicates))
+}
@@ -0,0 +1,40 @@
+// Command reindex rebuilds the search index from the movies table and removes
+// the entries of deleted movies and of words they no longer contain. Run it
+// once after creating the search table, and again if cmd/indexer logged
+// "unable to update search index" or the tokens indexed per movie changed. It
+// uses the same configuration as the Lambda.
+//
+//	go run ./cmd/reindex
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+
+	"github.com/AJ-Walker/movies-rest-api-lambda/api"
+)
+
+func main() {
+	ctx := context.Background()
+
+	config, err := api.LoadConfig()
+	if err != nil {
+		slog.Error("unable to load configuration", "error", err)
+		os.Exit(1)
+	}
+	slog.SetDefault(api.NewLogger(config.LogLevel))
+
+	awsConfig, err := api.LoadAWSConfig(ctx, config)
+	if err != nil {
+		slog.Error("unable to load AWS configuration", "error", err)
+		os.Exit(1)
+	}
+
+	rebuild, err := api.NewDynamoStore(awsConfig, config).RebuildSearchIndex(ctx)
+	if err != nil {
+		slog.Error("unable to rebuild search index", "indexed", rebuild.Indexed, "error", err)
+		os.Exit(1)
+	}
+	slog.Info("search index rebuilt", "table", config.SearchTableName, "movies", rebuild.Indexed, "removed", rebuild.Removed)
+}
@@ -9,6 +9,7 @@ require (
 	github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue v1.18.8
 	github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression v1.7.75
 	github.com/aws/aws-sdk-go-v2/service/dynamodb v1.42.0
+	golang.org/x/image v0.25.0
 )
 
 require (